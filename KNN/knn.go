@@ -8,6 +8,7 @@ import(
 type DataPoint struct {
 	Features []float64
 	Label    string
+	Target   float64 // Continuous target used for regression
 }
 
 func euclideanDistance(p1, p2 []float64) float64 {
@@ -20,6 +21,19 @@ func euclideanDistance(p1, p2 []float64) float64 {
 }
 
 func findKNearestNeighbors(data []DataPoint, query []float64, k int) []string {
+	sortedIndices, _ := sortByDistance(data, query)
+
+	// Get the labels of the k nearest neighbors
+	nearestLabels := make([]string, k)
+	for i := 0; i < k; i++ {
+		nearestLabels[i] = data[sortedIndices[i]].Label
+	}
+
+	return nearestLabels
+}
+
+// sortByDistance returns the indices of the data points ordered by distance to the query, and the sorted distances
+func sortByDistance(data []DataPoint, query []float64) ([]int, []float64) {
 	distances := make([]float64, len(data))
 	for i, point := range data {
 		distances[i] = euclideanDistance(point.Features, query)
@@ -39,13 +53,54 @@ func findKNearestNeighbors(data []DataPoint, query []float64, k int) []string {
 		}
 	}
 
-	// Get the labels of the k nearest neighbors
-	nearestLabels := make([]string, k)
+	return sortedIndices, distances
+}
+
+// PredictRegression predicts the target of the query as the weighted average of its k nearest neighbors' targets.
+// The kernel sets each neighbor's weight from its distance d:
+// "uniform" weighs all neighbors equally, "distance" uses 1/d, and
+// "gaussian" uses exp(-d²/(2·bandwidth²)), giving smoother predictions as the bandwidth grows.
+// It returns an error for empty data, k < 1, or a gaussian kernel with bandwidth <= 0.
+func PredictRegression(data []DataPoint, query []float64, k int, kernel string, bandwidth float64) (float64, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("no data points")
+	}
+	if k < 1 {
+		return 0, fmt.Errorf("k must be at least 1, got %d", k)
+	}
+	if kernel == "gaussian" && !(bandwidth > 0) {
+		return 0, fmt.Errorf("gaussian kernel needs a positive bandwidth, got %v", bandwidth)
+	}
+	if k > len(data) {
+		k = len(data)
+	}
+	sortedIndices, distances := sortByDistance(data, query)
+
+	weightedSum := 0.0
+	totalWeight := 0.0
 	for i := 0; i < k; i++ {
-		nearestLabels[i] = data[sortedIndices[i]].Label
+		target := data[sortedIndices[i]].Target
+		var weight float64
+		switch kernel {
+		case "distance":
+			if distances[i] == 0 {
+				return target, nil
+			}
+			weight = 1 / distances[i]
+		case "gaussian":
+			weight = math.Exp(-distances[i] * distances[i] / (2 * bandwidth * bandwidth))
+		default:
+			weight = 1
+		}
+		weightedSum += weight * target
+		totalWeight += weight
 	}
 
-	return nearestLabels
+	if totalWeight == 0 {
+		// Every neighbor lies far outside the bandwidth, fall back to the nearest one
+		return data[sortedIndices[0]].Target, nil
+	}
+	return weightedSum / totalWeight, nil
 }
 
 func main() {
@@ -65,4 +120,20 @@ func main() {
 	nearestLabels := findKNearestNeighbors(data, query, k)
 
 	fmt.Printf("Query point belongs to labels: %v\n", nearestLabels)
+
+	// Sample regression dataset
+	regressionData := []DataPoint{
+		{Features: []float64{1}, Target: 1.2},
+		{Features: []float64{2}, Target: 1.9},
+		{Features: []float64{3}, Target: 3.1},
+		{Features: []float64{4}, Target: 3.9},
+	}
+
+	// Predict with a Gaussian kernel
+	prediction, err := PredictRegression(regressionData, []float64{2.5}, 3, "gaussian", 1.0)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Kernel regression prediction: %.2f\n", prediction)
 }
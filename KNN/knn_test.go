@@ -0,0 +1,35 @@
+package KNN
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPredictRegressionGaussianBandwidth(t *testing.T) {
+	data := []DataPoint{
+		{Features: []float64{1}, Target: 1},
+		{Features: []float64{2}, Target: 2},
+		{Features: []float64{3}, Target: 3},
+	}
+
+	for _, bandwidth := range []float64{0, -1, math.NaN()} {
+		if _, err := PredictRegression(data, []float64{2}, 3, "gaussian", bandwidth); err == nil {
+			t.Errorf("bandwidth %v: expected an error", bandwidth)
+		}
+	}
+
+	// Symmetric neighbors average to the middle target
+	prediction, err := PredictRegression(data, []float64{2}, 3, "gaussian", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(prediction-2) > 1e-12 {
+		t.Errorf("prediction = %v, want 2", prediction)
+	}
+
+	// A tiny bandwidth underflows every weight and falls back to the nearest neighbor
+	prediction, err = PredictRegression(data, []float64{2.9}, 3, "gaussian", 1e-3)
+	if err != nil || prediction != 3 {
+		t.Errorf("prediction = %v, %v; want the nearest target 3", prediction, err)
+	}
+}
@@ -1,8 +1,13 @@
 package LogisticReg
 
 import(
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
+	"math/rand"
+
+	"ml/metrics"
 )
 
 // LogisticRegression struct represents the logistic regression model
@@ -10,6 +15,12 @@ type LogisticRegression struct {
 	Weights []float64 // Coefficients for the logistic regression model
 	LearningRate float64 // Learning rate for gradient descent
 	Epochs int // Number of training epochs
+	Momentum float64 // Fraction of the previous update carried into the next one
+	BatchSize int // Number of samples per gradient step; 0 updates on every sample in order
+	Seed int64 // Seed of the random source used to shuffle rows between epochs
+	ClassWeights map[int]float64 // Weight of each label's gradient contribution; missing labels weigh 1
+	ClassWeightMode string // "balanced" derives ClassWeights from the label frequencies during Train
+	FeatureNames []string // Optional names of the input columns
 }
 
 // NewLogisticRegression initializes a new logistic regression model with default parameters
@@ -34,6 +45,26 @@ func (lr *LogisticRegression) Predict(X []float64) float64 {
 	return Sigmoid(y)
 }
 
+// PredictBatch computes the predicted probability for every row of X
+func (lr *LogisticRegression) PredictBatch(X [][]float64) []float64 {
+	predictions := make([]float64, len(X))
+	for i, xi := range X {
+		predictions[i] = lr.Predict(xi)
+	}
+	return predictions
+}
+
+// PredictClassBatch returns 1 for every row of X whose predicted probability is at least threshold, and 0 otherwise
+func (lr *LogisticRegression) PredictClassBatch(X [][]float64, threshold float64) []int {
+	classes := make([]int, len(X))
+	for i, prob := range lr.PredictBatch(X) {
+		if prob >= threshold {
+			classes[i] = 1
+		}
+	}
+	return classes
+}
+
 // Train fits the logistic regression model to the training data
 func (lr *LogisticRegression) Train(X [][]float64, y []int) {
 	// Initialize weights
@@ -42,18 +73,99 @@ func (lr *LogisticRegression) Train(X [][]float64, y []int) {
 		lr.Weights[i] = 0.0
 	}
 
-	// Gradient Descent
+	// Derive class weights inversely proportional to the class frequencies
+	if lr.ClassWeightMode == "balanced" {
+		lr.ClassWeights = BalancedClassWeights(y)
+	}
+
+	// Samples are visited in order unless mini-batches are requested
+	batchSize := lr.BatchSize
+	var rng *rand.Rand
+	if batchSize > 0 {
+		rng = rand.New(rand.NewSource(lr.Seed))
+	} else {
+		batchSize = 1
+	}
+	order := make([]int, len(X))
+	for i := range order {
+		order[i] = i
+	}
+
+	// Mini-batch Gradient Descent with momentum
+	velocity := make([]float64, len(lr.Weights))
+	gradient := make([]float64, len(lr.Weights))
 	for epoch := 0; epoch < lr.Epochs; epoch++ {
-		for i, xi := range X {
-			predicted := lr.Predict(xi)
-			error := float64(y[i]) - predicted
+		if rng != nil {
+			rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		}
+		for start := 0; start < len(order); start += batchSize {
+			end := start + batchSize
+			if end > len(order) {
+				end = len(order)
+			}
+
+			// Average the gradient over the batch
+			for j := range gradient {
+				gradient[j] = 0
+			}
+			for _, i := range order[start:end] {
+				predicted := lr.Predict(X[i])
+				error := float64(y[i]) - predicted
+				if weight, ok := lr.ClassWeights[y[i]]; ok {
+					error *= weight
+				}
+				for j := range gradient {
+					gradient[j] += error * X[i][j]
+				}
+			}
+
+			// Accumulate velocity and update weights
 			for j := range lr.Weights {
-				lr.Weights[j] += lr.LearningRate * error * xi[j]
+				velocity[j] = lr.Momentum*velocity[j] + lr.LearningRate*gradient[j]/float64(end-start)
+				lr.Weights[j] += velocity[j]
 			}
 		}
 	}
 }
 
+// Coefficients returns the trained weight of each feature
+func (lr *LogisticRegression) Coefficients() []float64 {
+	return lr.Weights
+}
+
+// CoefficientsNamed returns the trained weight of each feature keyed by its name
+func (lr *LogisticRegression) CoefficientsNamed() map[string]float64 {
+	return metrics.NamedValues(lr.FeatureNames, lr.Coefficients())
+}
+
+// Save writes the model's weights and training parameters to w as JSON
+func (lr *LogisticRegression) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(lr)
+}
+
+// LoadLogisticRegression reads a model written by Save from r
+func LoadLogisticRegression(r io.Reader) (*LogisticRegression, error) {
+	lr := &LogisticRegression{}
+	if err := json.NewDecoder(r).Decode(lr); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
+
+// BalancedClassWeights returns class weights inversely proportional to the class frequencies in y,
+// so that every class contributes the same total weight
+func BalancedClassWeights(y []int) map[int]float64 {
+	counts := make(map[int]int)
+	for _, label := range y {
+		counts[label]++
+	}
+	weights := make(map[int]float64)
+	for label, count := range counts {
+		weights[label] = float64(len(y)) / (float64(len(counts)) * float64(count))
+	}
+	return weights
+}
+
 func main() {
 	// Example usage
 	X := [][]float64{{1, 2}, {2, 3}, {3, 4}, {4, 5}, {5, 6}}
@@ -0,0 +1,52 @@
+package LogisticReg
+
+import (
+	"math"
+	"testing"
+)
+
+// logLoss returns the mean cross-entropy of the model's probabilities for X against y
+func logLoss(lr *LogisticRegression, X [][]float64, y []int) float64 {
+	loss := 0.0
+	for i, prob := range lr.PredictBatch(X) {
+		if y[i] == 1 {
+			loss -= math.Log(prob)
+		} else {
+			loss -= math.Log(1 - prob)
+		}
+	}
+	return loss / float64(len(X))
+}
+
+func TestMomentumConvergesFaster(t *testing.T) {
+	X := [][]float64{{1, 2}, {2, 3}, {3, 4}, {4, 5}, {5, 6}}
+	y := []int{0, 0, 1, 1, 1}
+
+	plain := NewLogisticRegression()
+	plain.Epochs = 100
+	plain.Train(X, y)
+
+	momentum := NewLogisticRegression()
+	momentum.Epochs = 100
+	momentum.Momentum = 0.9
+	momentum.BatchSize = 2
+	momentum.Seed = 1
+	momentum.Train(X, y)
+
+	if plainLoss, momentumLoss := logLoss(plain, X, y), logLoss(momentum, X, y); momentumLoss >= plainLoss {
+		t.Errorf("loss after 100 epochs with momentum %v, without %v", momentumLoss, plainLoss)
+	}
+
+	// The same seed shuffles the rows the same way
+	again := NewLogisticRegression()
+	again.Epochs = 100
+	again.Momentum = 0.9
+	again.BatchSize = 2
+	again.Seed = 1
+	again.Train(X, y)
+	for j := range momentum.Weights {
+		if again.Weights[j] != momentum.Weights[j] {
+			t.Fatalf("weights %v differ from %v with the same seed", again.Weights, momentum.Weights)
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package MDPs
 
 import(
+	"encoding/json"
 	"fmt"
-	"math/rand"
 	"math"
+	"os"
 )
 
 // State represents a state in the MDP
@@ -49,16 +50,136 @@ func (mdp *MDP) AddReward(s State, a Action, reward float64) {
 	mdp.Rewards[s][a] = reward
 }
 
-// PolicyIteration finds the optimal policy using policy iteration algorithm
-func (mdp *MDP) PolicyIteration(gamma float64, epsilon float64) map[State]Action {
+// probabilityTolerance is the allowed deviation of a state-action's transition probabilities from 1
+const probabilityTolerance = 1e-6
+
+// mdpFile is the JSON layout read by LoadMDP
+type mdpFile struct {
+	NumStates   int `json:"numStates"`
+	NumActions  int `json:"numActions"`
+	Transitions []struct {
+		State       State   `json:"state"`
+		Action      Action  `json:"action"`
+		NextState   State   `json:"nextState"`
+		Probability float64 `json:"probability"`
+	} `json:"transitions"`
+	Rewards []struct {
+		State  State   `json:"state"`
+		Action Action  `json:"action"`
+		Reward float64 `json:"reward"`
+	} `json:"rewards"`
+}
+
+// LoadMDP reads an MDP from a JSON file of the form
+//
+//	{"numStates": 2, "numActions": 1,
+//	 "transitions": [{"state": 0, "action": 0, "nextState": 1, "probability": 1.0}],
+//	 "rewards": [{"state": 0, "action": 0, "reward": 0.5}]}
+//
+// and validates it with Validate
+func LoadMDP(path string) (*MDP, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var spec mdpFile
+	if err := json.NewDecoder(file).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	mdp := NewMDP(spec.NumStates, spec.NumActions)
+	for _, t := range spec.Transitions {
+		mdp.AddTransition(t.State, t.Action, t.NextState, t.Probability)
+	}
+	for _, r := range spec.Rewards {
+		mdp.AddReward(r.State, r.Action, r.Reward)
+	}
+
+	if err := mdp.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MDP in %s: %w", path, err)
+	}
+	return mdp, nil
+}
+
+// Validate checks that every state and action referenced by the transitions and rewards is in range
+// and that the transition probabilities of every state-action pair sum to 1
+func (mdp *MDP) Validate() error {
+	// Check that referenced states and actions are in range
+	for s, actions := range mdp.Transitions {
+		if !mdp.validState(s) {
+			return fmt.Errorf("transition from state %d out of range [0, %d)", s, mdp.NumStates)
+		}
+		for a, next := range actions {
+			if !mdp.validAction(a) {
+				return fmt.Errorf("transition from state %d uses action %d out of range [0, %d)", s, a, mdp.NumActions)
+			}
+			for sPrime := range next {
+				if !mdp.validState(sPrime) {
+					return fmt.Errorf("transition from state %d action %d leads to state %d out of range [0, %d)", s, a, sPrime, mdp.NumStates)
+				}
+			}
+		}
+	}
+	for s, actions := range mdp.Rewards {
+		if !mdp.validState(s) {
+			return fmt.Errorf("reward for state %d out of range [0, %d)", s, mdp.NumStates)
+		}
+		for a := range actions {
+			if !mdp.validAction(a) {
+				return fmt.Errorf("reward for state %d uses action %d out of range [0, %d)", s, a, mdp.NumActions)
+			}
+		}
+	}
+
+	// Check that the outgoing probabilities of every state-action pair form a distribution
+	for s := 0; s < mdp.NumStates; s++ {
+		for a := 0; a < mdp.NumActions; a++ {
+			next, ok := mdp.Transitions[State(s)][Action(a)]
+			if !ok {
+				continue
+			}
+			sum := 0.0
+			for sPrime, prob := range next {
+				if prob < 0 {
+					return fmt.Errorf("transition from state %d action %d to state %d has negative probability %g", s, a, sPrime, prob)
+				}
+				sum += prob
+			}
+			if math.Abs(sum-1) > probabilityTolerance {
+				return fmt.Errorf("transition probabilities of state %d action %d sum to %g", s, a, sum)
+			}
+		}
+	}
+	return nil
+}
+
+// validState reports whether s is a state of the MDP
+func (mdp *MDP) validState(s State) bool {
+	return s >= 0 && int(s) < mdp.NumStates
+}
+
+// validAction reports whether a is an action of the MDP
+func (mdp *MDP) validAction(a Action) bool {
+	return a >= 0 && int(a) < mdp.NumActions
+}
+
+// tieTolerance is the margin by which an action must beat a lower-indexed one to be preferred
+const tieTolerance = 1e-12
+
+// PolicyIteration finds the optimal policy using policy iteration algorithm.
+// It stops after maxIterations policy improvements and reports whether the policy converged;
+// the best policy found so far is returned either way.
+func (mdp *MDP) PolicyIteration(gamma float64, epsilon float64, maxIterations int) (map[State]Action, bool) {
 	// Initialize arbitrary policy
 	policy := make(map[State]Action)
 	for s := 0; s < mdp.NumStates; s++ {
-		policy[State(s)] = Action(rand.Intn(mdp.NumActions))
+		policy[State(s)] = Action(0)
 	}
 
 	// Iterate until policy converges
-	for {
+	for iteration := 0; iteration < maxIterations; iteration++ {
 		// Policy Evaluation
 		V := make(map[State]float64)
 		for s := 0; s < mdp.NumStates; s++ {
@@ -90,14 +211,15 @@ func (mdp *MDP) PolicyIteration(gamma float64, epsilon float64) map[State]Action
 		for s := 0; s < mdp.NumStates; s++ {
 			oldAction := policy[State(s)]
 			maxAction := Action(0)
-			maxQ := -1e9
+			maxQ := math.Inf(-1)
 			for a := 0; a < mdp.NumActions; a++ {
 				action := Action(a)
 				q := mdp.Rewards[State(s)][action]
 				for sPrime, prob := range mdp.Transitions[State(s)][action] {
 					q += gamma * prob * V[sPrime]
 				}
-				if q > maxQ {
+				// Ties go to the lower action index to avoid oscillating between equally good actions
+				if q > maxQ+tieTolerance {
 					maxQ = q
 					maxAction = action
 				}
@@ -109,11 +231,11 @@ func (mdp *MDP) PolicyIteration(gamma float64, epsilon float64) map[State]Action
 		}
 
 		if policyStable {
-			break
+			return policy, true
 		}
 	}
 
-	return policy
+	return policy, false
 }
 
 func main() {
@@ -136,8 +258,16 @@ func main() {
 	mdp.AddReward(2, 0, 0.1)
 	mdp.AddReward(2, 1, -0.5)
 
+	// Check the specification before solving
+	if err := mdp.Validate(); err != nil {
+		fmt.Println("Invalid MDP:", err)
+	}
+
 	// Perform policy iteration to find optimal policy
-	optimalPolicy := mdp.PolicyIteration(0.9, 0.01)
+	optimalPolicy, converged := mdp.PolicyIteration(0.9, 0.01, 100)
+	if !converged {
+		fmt.Println("Policy iteration did not converge")
+	}
 
 	// Print optimal policy
 	fmt.Println("Optimal Policy:")
@@ -9,6 +9,7 @@ import(
 type NaiveBayes struct {
     classCounts map[string]int
     wordCounts  map[string]map[string]int
+    priors      map[string]float64
 }
 
 // NewNaiveBayes initializes a new NaiveBayes classifier.
@@ -33,6 +34,54 @@ func (nb *NaiveBayes) Train(data [][]string, labels []string) {
     }
 }
 
+// SetPriors overrides the class priors learned from the training data.
+// Classes missing from priors keep their empirical prior, and the merged priors are normalized to sum to 1.
+// nil restores the empirical priors for every class. Negative priors or priors that sum to 0 are rejected.
+func (nb *NaiveBayes) SetPriors(priors map[string]float64) error {
+    if priors == nil {
+        nb.priors = nil
+        return nil
+    }
+    total := 0.0
+    for label, prior := range priors {
+        if prior < 0 || math.IsNaN(prior) {
+            return fmt.Errorf("prior of class %q must be non-negative, got %v", label, prior)
+        }
+        total += prior
+    }
+    if total <= 0 {
+        return fmt.Errorf("priors must sum to a positive value, got %v", total)
+    }
+    nb.priors = make(map[string]float64)
+    for label, prior := range priors {
+        nb.priors[label] = prior
+    }
+    return nil
+}
+
+// prior returns the prior of a class: its set prior or its empirical prior, normalized over all trained classes
+func (nb *NaiveBayes) prior(label string) float64 {
+    totalDocs := 0
+    for _, count := range nb.classCounts {
+        totalDocs += count
+    }
+    classPrior := func(label string) float64 {
+        if prior, ok := nb.priors[label]; ok {
+            return prior
+        }
+        return float64(nb.classCounts[label]) / float64(totalDocs)
+    }
+
+    total := 0.0
+    for other := range nb.classCounts {
+        total += classPrior(other)
+    }
+    if total == 0 {
+        return 0
+    }
+    return classPrior(label) / total
+}
+
 // Predict predicts the class label for the given input.
 func (nb *NaiveBayes) Predict(input []string) string {
     var bestLabel string
@@ -48,9 +97,51 @@ func (nb *NaiveBayes) Predict(input []string) string {
     return bestLabel
 }
 
-// calculateClassProbability calculates the probability of the given input belonging to the specified class.
+// PredictProba returns the normalized probability of the given input belonging to each class.
+func (nb *NaiveBayes) PredictProba(input []string) map[string]float64 {
+    logProbs := make(map[string]float64)
+    for label := range nb.classCounts {
+        logProbs[label] = nb.calculateClassProbability(input, label)
+    }
+    return normalizeLogProbabilities(logProbs)
+}
+
+// normalizeLogProbabilities converts class log-scores to probabilities using the log-sum-exp trick,
+// subtracting the largest log-score before exponentiating so that the result neither overflows nor underflows.
+func normalizeLogProbabilities(logProbs map[string]float64) map[string]float64 {
+    probs := make(map[string]float64)
+    maxLogProb := math.Inf(-1)
+    for _, logProb := range logProbs {
+        if logProb > maxLogProb {
+            maxLogProb = logProb
+        }
+    }
+
+    // Every class is impossible, fall back to a uniform distribution
+    if math.IsInf(maxLogProb, -1) {
+        for label := range logProbs {
+            probs[label] = 1 / float64(len(logProbs))
+        }
+        return probs
+    }
+
+    sum := 0.0
+    for label, logProb := range logProbs {
+        probs[label] = math.Exp(logProb - maxLogProb)
+        sum += probs[label]
+    }
+    for label := range probs {
+        probs[label] /= sum
+    }
+    return probs
+}
+
+// calculateClassProbability calculates the log-probability of the given input belonging to the specified class.
 func (nb *NaiveBayes) calculateClassProbability(input []string, label string) float64 {
-    prob := math.Log(float64(nb.classCounts[label]) / float64(len(nb.classCounts)))
+    if nb.classCounts[label] == 0 {
+        return math.Inf(-1)
+    }
+    prob := math.Log(nb.prior(label))
     for _, word := range input {
         if nb.wordCounts[label][word] > 0 {
             prob += math.Log(float64(nb.wordCounts[label][word]) / float64(nb.classCounts[label]))
@@ -80,4 +171,7 @@ func main() {
     // Predict the class label
     predictedLabel := nb.Predict(input)
     fmt.Println("Predicted label:", predictedLabel)
+
+    // Print the probability of each class
+    fmt.Println("Class probabilities:", nb.PredictProba(input))
 }
@@ -0,0 +1,33 @@
+package Naivebayes
+
+import (
+    "math"
+    "testing"
+)
+
+func TestSetPriors(t *testing.T) {
+    nb := NewNaiveBayes()
+    nb.Train([][]string{{"a"}, {"b"}, {"c"}, {"d"}}, []string{"x", "y", "z", "z"})
+
+    if err := nb.SetPriors(map[string]float64{"x": 0, "y": 0}); err == nil {
+        t.Error("expected an error for priors that sum to 0")
+    }
+    if err := nb.SetPriors(map[string]float64{"x": -1, "y": 2}); err == nil {
+        t.Error("expected an error for a negative prior")
+    }
+
+    // z keeps its empirical prior 0.5, and the merged priors are normalized together
+    if err := nb.SetPriors(map[string]float64{"x": 0.5, "y": 0.5}); err != nil {
+        t.Fatal(err)
+    }
+    total := 0.0
+    for _, label := range []string{"x", "y", "z"} {
+        total += nb.prior(label)
+    }
+    if math.Abs(total-1) > 1e-12 {
+        t.Errorf("priors sum to %v, want 1", total)
+    }
+    if math.Abs(nb.prior("z")-1.0/3) > 1e-12 || math.Abs(nb.prior("x")-1.0/3) > 1e-12 {
+        t.Errorf("priors x=%v z=%v, want 1/3 each", nb.prior("x"), nb.prior("z"))
+    }
+}
@@ -3,11 +3,17 @@ package adaboost
 import(
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 )
 
 type AdaBoost struct {
 	WeakLearners []WeakLearner
 	Alpha        []float64
+	// MaxThresholds limits the candidate thresholds evaluated per feature and iteration
+	// to a random quantile sample of that size; 0 evaluates every distinct value
+	MaxThresholds int
+	Seed          int64 // Seed of the random source used to sample thresholds
 }
 
 type WeakLearner struct {
@@ -30,14 +36,26 @@ func (adaboost *AdaBoost) Train(X [][]float64, y []float64, numIterations int) {
 		weights[i] = 1.0 / float64(numSamples)
 	}
 
+	// Collect the sorted distinct values of each feature once
+	featureThresholds := make([][]float64, numFeatures)
+	for j := range featureThresholds {
+		featureThresholds[j] = findThresholds(X, j)
+		sort.Float64s(featureThresholds[j])
+	}
+	rng := rand.New(rand.NewSource(adaboost.Seed))
+
 	for t := 0; t < numIterations; t++ {
 		weakLearner := WeakLearner{}
 		errorRate := math.MaxFloat64
 
 		// Find the best weak learner
 		for j := 0; j < numFeatures; j++ {
+			thresholds := featureThresholds[j]
+			if adaboost.MaxThresholds > 0 {
+				thresholds = sampleThresholds(thresholds, adaboost.MaxThresholds, rng)
+			}
 			for _, direction := range []int{-1, 1} {
-				for _, threshold := range findThresholds(X, j) {
+				for _, threshold := range thresholds {
 					prediction := makePrediction(X, j, threshold, direction)
 					weightedError := calculateWeightedError(weights, y, prediction)
 
@@ -86,6 +104,22 @@ func findThresholds(X [][]float64, featureIndex int) []float64 {
 	return result
 }
 
+// sampleThresholds draws one random threshold from each of n equally sized quantile ranges of the sorted thresholds
+func sampleThresholds(sorted []float64, n int, rng *rand.Rand) []float64 {
+	if len(sorted) <= n {
+		return sorted
+	}
+	sampled := make([]float64, n)
+	for q := range sampled {
+		index := int((float64(q) + rng.Float64()) * float64(len(sorted)) / float64(n))
+		if index >= len(sorted) {
+			index = len(sorted) - 1
+		}
+		sampled[q] = sorted[index]
+	}
+	return sampled
+}
+
 func makePrediction(X [][]float64, featureIndex int, threshold float64, direction int) []float64 {
 	var predictions []float64
 	for _, sample := range X {
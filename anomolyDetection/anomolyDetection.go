@@ -1,12 +1,18 @@
 package anomolyDetection
 
 import(
+	"bufio"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
+	"sync"
 )
 
 // Point represents a data point in the dataset
@@ -16,16 +22,70 @@ type Point []float64
 type IsolationTreeNode struct {
 	SplitFeature int
 	SplitValue   float64
+	Categorical  bool      // Whether the split sends points whose feature equals SplitValue left, rather than those below it
+	Normal       []float64 // Normal vector of the splitting hyperplane in extended trees; nil for feature splits
+	Intercept    []float64 // Point on the splitting hyperplane in extended trees
 	Left         *IsolationTreeNode
 	Right        *IsolationTreeNode
 	Size         int
 }
 
+// goesLeft reports whether a data point falls on the left side of the node's split
+func (node *IsolationTreeNode) goesLeft(point []float64) bool {
+	if node.Categorical {
+		return point[node.SplitFeature] == node.SplitValue
+	}
+	if node.Normal == nil {
+		return point[node.SplitFeature] < node.SplitValue
+	}
+	return hyperplaneSide(point, node.Normal, node.Intercept) < 0
+}
+
+// hyperplaneSide returns the dot product of a point's offset from the intercept with the normal vector,
+// which is negative on the left side of the hyperplane
+func hyperplaneSide(point, normal, intercept []float64) float64 {
+	sum := 0.0
+	for j, n := range normal {
+		sum += (point[j] - intercept[j]) * n
+	}
+	return sum
+}
+
 // IsolationForest represents an ensemble of isolation trees
 type IsolationForest struct {
 	Trees       []*IsolationTreeNode
 	NumTrees    int
 	MaxTreeDepth int
+	Threshold   float64 // Anomaly scores at or above the threshold are anomalies
+	SampleSize  int     // Number of points drawn without replacement to build each tree; 0 uses all the data
+	Seed        int64   // Seed from which the random source of every tree is derived
+	NumWorkers  int     // Number of trees built concurrently; 0 uses one worker per CPU
+	CategoricalFeatures []bool // Features holding category codes, split by equality with a random category; nil means all numeric
+	numSamples  int     // Number of points each tree was built from, which normalizes the path lengths; set by Train
+}
+
+// forestJSON is the JSON form of an IsolationForest, which also records the sample size the trees were built from
+type forestJSON struct {
+	*forestFields
+	NumSamples int
+}
+
+// forestFields has the fields of IsolationForest without its methods, so that encoding it does not recurse into MarshalJSON
+type forestFields IsolationForest
+
+// MarshalJSON encodes the forest and its trees
+func (forest *IsolationForest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(forestJSON{forestFields: (*forestFields)(forest), NumSamples: forest.numSamples})
+}
+
+// UnmarshalJSON decodes a forest written by MarshalJSON
+func (forest *IsolationForest) UnmarshalJSON(data []byte) error {
+	decoded := forestJSON{forestFields: (*forestFields)(forest)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	forest.numSamples = decoded.NumSamples
+	return nil
 }
 
 // NewIsolationForest initializes a new IsolationForest
@@ -34,49 +94,218 @@ func NewIsolationForest(numTrees, maxTreeDepth int) *IsolationForest {
 		Trees:       make([]*IsolationTreeNode, numTrees),
 		NumTrees:    numTrees,
 		MaxTreeDepth: maxTreeDepth,
+		Threshold:   0.5,
+		SampleSize:  256,
+	}
+}
+
+// Save writes the trained forest, including its hyperparameters, threshold and every tree, to w as JSON
+func (forest *IsolationForest) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(forest)
+}
+
+// Load reads a forest written by Save from r. Leaves are written with null children and reload as leaves.
+func Load(r io.Reader) (*IsolationForest, error) {
+	forest := &IsolationForest{}
+	if err := json.NewDecoder(r).Decode(forest); err != nil {
+		return nil, err
 	}
+	return forest, nil
+}
+
+// ExtendedIsolationForest is an isolation forest whose trees split on random hyperplanes instead of
+// thresholds on single features, which avoids the axis-aligned artifacts of the scores.
+// Hyperplanes treat every feature as numeric, so CategoricalFeatures is ignored.
+type ExtendedIsolationForest struct {
+	IsolationForest
+}
+
+// NewExtendedIsolationForest initializes a new ExtendedIsolationForest
+func NewExtendedIsolationForest(numTrees, maxTreeDepth int) *ExtendedIsolationForest {
+	return &ExtendedIsolationForest{IsolationForest: *NewIsolationForest(numTrees, maxTreeDepth)}
 }
 
-// Train builds isolation trees in the forest
+// treeBuilder builds an isolation tree from a subsample of the data
+type treeBuilder func(data [][]float64, currentDepth, maxDepth int, rng *rand.Rand) *IsolationTreeNode
+
+// Train builds isolation trees in the forest, each from its own random subsample of SampleSize points.
+// Trees are built concurrently, each from a random source seeded by a value drawn in order from Seed,
+// so the forest depends on Seed but not on NumWorkers.
 func (forest *IsolationForest) Train(data [][]float64) {
+	forest.train(data, func(data [][]float64, currentDepth, maxDepth int, rng *rand.Rand) *IsolationTreeNode {
+		return buildIsolationTree(data, currentDepth, maxDepth, forest.CategoricalFeatures, rng)
+	})
+}
+
+// Train builds extended isolation trees in the forest, in the same way as IsolationForest.Train
+func (forest *ExtendedIsolationForest) Train(data [][]float64) {
+	forest.train(data, buildExtendedIsolationTree)
+}
+
+// train builds the trees of the forest with build
+func (forest *IsolationForest) train(data [][]float64, build treeBuilder) {
+	forest.numSamples = forest.SampleSize
+	if forest.numSamples <= 0 || forest.numSamples > len(data) {
+		forest.numSamples = len(data)
+	}
+
+	seeds := rand.New(rand.NewSource(forest.Seed))
+	treeSeeds := make([]int64, forest.NumTrees)
+	for i := range treeSeeds {
+		treeSeeds[i] = seeds.Int63()
+	}
+
+	numWorkers := forest.NumWorkers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(0))
+			for i := range jobs {
+				rng.Seed(treeSeeds[i])
+				forest.Trees[i] = build(subsample(data, forest.numSamples, rng), 0, forest.MaxTreeDepth, rng)
+			}
+		}()
+	}
 	for i := 0; i < forest.NumTrees; i++ {
-		tree := buildIsolationTree(data, 0, forest.MaxTreeDepth)
-		forest.Trees[i] = tree
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// subsample draws size points from data without replacement
+func subsample(data [][]float64, size int, rng *rand.Rand) [][]float64 {
+	indices := make([]int, len(data))
+	for i := range indices {
+		indices[i] = i
 	}
+	sample := make([][]float64, size)
+	for i := 0; i < size; i++ {
+		j := i + rng.Intn(len(data)-i)
+		indices[i], indices[j] = indices[j], indices[i]
+		sample[i] = data[indices[i]]
+	}
+	return sample
+}
+
+// buildIsolationTree recursively builds an isolation tree, splitting the categorical features by equality
+func buildIsolationTree(data [][]float64, currentDepth, maxDepth int, categorical []bool, rng *rand.Rand) *IsolationTreeNode {
+	if len(data) <= 1 || currentDepth >= maxDepth {
+		return &IsolationTreeNode{Size: len(data)}
+	}
+
+	// Only features that vary can separate the points; if none do, the points are identical and cannot be isolated
+	features := varyingFeatures(data)
+	if len(features) == 0 {
+		return &IsolationTreeNode{Size: len(data)}
+	}
+	splitFeature := features[rng.Intn(len(features))]
+	isCategorical := splitFeature < len(categorical) && categorical[splitFeature]
+	var splitValue float64
+	if isCategorical {
+		categories := distinctValues(data, splitFeature)
+		splitValue = categories[rng.Intn(len(categories))]
+	} else {
+		minValue, maxValue := findMinMax(data, splitFeature)
+		splitValue = rng.Float64() * (maxValue - minValue) + minValue
+	}
+	node := &IsolationTreeNode{
+		SplitFeature: splitFeature,
+		SplitValue:   splitValue,
+		Categorical:  isCategorical,
+		Size:         len(data),
+	}
+
+	leftData := make([][]float64, 0)
+	rightData := make([][]float64, 0)
+
+	for _, point := range data {
+		if node.goesLeft(point) {
+			leftData = append(leftData, point)
+		} else {
+			rightData = append(rightData, point)
+		}
+	}
+
+	node.Left = buildIsolationTree(leftData, currentDepth+1, maxDepth, categorical, rng)
+	node.Right = buildIsolationTree(rightData, currentDepth+1, maxDepth, categorical, rng)
+	return node
+}
+
+// distinctValues returns the distinct values of a feature in the dataset in increasing order
+func distinctValues(data [][]float64, featureIndex int) []float64 {
+	seen := make(map[float64]bool)
+	var values []float64
+	for _, point := range data {
+		if !seen[point[featureIndex]] {
+			seen[point[featureIndex]] = true
+			values = append(values, point[featureIndex])
+		}
+	}
+	sort.Float64s(values)
+	return values
 }
 
-// buildIsolationTree recursively builds an isolation tree
-func buildIsolationTree(data [][]float64, currentDepth, maxDepth int) *IsolationTreeNode {
+// buildExtendedIsolationTree recursively builds an isolation tree that splits on hyperplanes with a normal vector
+// drawn from a standard normal distribution through a point drawn uniformly from the bounding box of the data
+func buildExtendedIsolationTree(data [][]float64, currentDepth, maxDepth int, rng *rand.Rand) *IsolationTreeNode {
 	if len(data) <= 1 || currentDepth >= maxDepth {
 		return &IsolationTreeNode{Size: len(data)}
 	}
 
+	// Identical points cannot be separated by any hyperplane
+	if len(varyingFeatures(data)) == 0 {
+		return &IsolationTreeNode{Size: len(data)}
+	}
+
 	numFeatures := len(data[0])
-	splitFeature := rand.Intn(numFeatures)
-	minValue, maxValue := findMinMax(data, splitFeature)
-	splitValue := rand.Float64() * (maxValue - minValue) + minValue
+	normal := make([]float64, numFeatures)
+	intercept := make([]float64, numFeatures)
+	for j := 0; j < numFeatures; j++ {
+		minValue, maxValue := findMinMax(data, j)
+		normal[j] = rng.NormFloat64()
+		intercept[j] = rng.Float64() * (maxValue - minValue) + minValue
+	}
 
 	leftData := make([][]float64, 0)
 	rightData := make([][]float64, 0)
 
 	for _, point := range data {
-		if point[splitFeature] < splitValue {
+		if hyperplaneSide(point, normal, intercept) < 0 {
 			leftData = append(leftData, point)
 		} else {
 			rightData = append(rightData, point)
 		}
 	}
 
-	left := buildIsolationTree(leftData, currentDepth+1, maxDepth)
-	right := buildIsolationTree(rightData, currentDepth+1, maxDepth)
+	left := buildExtendedIsolationTree(leftData, currentDepth+1, maxDepth, rng)
+	right := buildExtendedIsolationTree(rightData, currentDepth+1, maxDepth, rng)
 
 	return &IsolationTreeNode{
-		SplitFeature: splitFeature,
-		SplitValue:   splitValue,
-		Left:         left,
-		Right:        right,
-		Size:         len(data),
+		Normal:    normal,
+		Intercept: intercept,
+		Left:      left,
+		Right:     right,
+		Size:      len(data),
+	}
+}
+
+// varyingFeatures returns the features that take more than one value in the dataset
+func varyingFeatures(data [][]float64) []int {
+	var features []int
+	for j := range data[0] {
+		minValue, maxValue := findMinMax(data, j)
+		if minValue < maxValue {
+			features = append(features, j)
+		}
 	}
+	return features
 }
 
 // findMinMax finds the minimum and maximum values of a feature in the dataset
@@ -94,47 +323,276 @@ func findMinMax(data [][]float64, featureIndex int) (min, max float64) {
 	return min, max
 }
 
-// AnomalyScore calculates the anomaly score for a data point
+// AnomalyScore calculates the anomaly score for a data point.
+// A forest that has not been trained, or was trained on a single point, scores every point 0.
 func (forest *IsolationForest) AnomalyScore(point []float64) float64 {
-	if forest.NumTrees == 0 {
+	if forest.NumTrees == 0 || forest.numSamples < 2 {
 		return 0
 	}
 
 	avgPathLength := 0.0
 	for _, tree := range forest.Trees {
-		avgPathLength += float64(tree.Traverse(point, 0))
+		avgPathLength += tree.Traverse(point, 0)
 	}
 	avgPathLength /= float64(forest.NumTrees)
 
-	return math.Pow(2, -avgPathLength/(2*averagePathLength(forest.MaxTreeDepth)))
+	return math.Pow(2, -avgPathLength/averagePathLength(forest.numSamples))
 }
 
-// Traverse traverses the isolation tree and returns the path length for a data point
-func (node *IsolationTreeNode) Traverse(point []float64, currentDepth int) int {
-	if node == nil {
-		return currentDepth
+// Scores calculates the anomaly score of every data point
+func (forest *IsolationForest) Scores(data [][]float64) []float64 {
+	scores := make([]float64, len(data))
+	for i, point := range data {
+		scores[i] = forest.AnomalyScore(point)
+	}
+	return scores
+}
+
+// RankScores returns the percentile rank of every data point's anomaly score within the batch, from 0 for the
+// least anomalous point to 1 for the most anomalous. Tied scores share the average of their ranks.
+func (forest *IsolationForest) RankScores(data [][]float64) []float64 {
+	scores := forest.Scores(data)
+	ranks := make([]float64, len(scores))
+	if len(scores) == 1 {
+		ranks[0] = 1
+		return ranks
+	}
+
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+
+	for start := 0; start < len(order); {
+		end := start
+		for end+1 < len(order) && scores[order[end+1]] == scores[order[start]] {
+			end++
+		}
+		rank := float64(start+end) / 2 / float64(len(order)-1)
+		for i := start; i <= end; i++ {
+			ranks[order[i]] = rank
+		}
+		start = end + 1
+	}
+	return ranks
+}
+
+// Predict reports whether a data point is an anomaly, that is whether its anomaly score reaches the threshold
+func (forest *IsolationForest) Predict(point []float64) bool {
+	return forest.AnomalyScore(point) >= forest.Threshold
+}
+
+// FitThreshold sets the threshold so that the given fraction of the data, the points with the highest scores, are anomalies
+func (forest *IsolationForest) FitThreshold(data [][]float64, contamination float64) {
+	scores := forest.Scores(data)
+	sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+
+	numAnomalies := int(math.Round(contamination * float64(len(scores))))
+	if numAnomalies <= 0 {
+		// No score reaches the largest float, which unlike infinity can be saved as JSON
+		forest.Threshold = math.MaxFloat64
+		return
+	}
+	if numAnomalies > len(scores) {
+		numAnomalies = len(scores)
+	}
+	forest.Threshold = scores[numAnomalies-1]
+}
+
+// PathLengths returns the depth a data point reaches in each tree of the forest, one entry per tree.
+// The anomaly score also adds the average path length of the points left in the external node.
+func (forest *IsolationForest) PathLengths(point []float64) []int {
+	lengths := make([]int, len(forest.Trees))
+	for i, tree := range forest.Trees {
+		lengths[i] = tree.Depth(point)
+	}
+	return lengths
+}
+
+// Depth returns the number of edges from the node to the external node a data point reaches
+func (node *IsolationTreeNode) Depth(point []float64) int {
+	depth := 0
+	for node != nil && (node.Left != nil || node.Right != nil) {
+		if node.goesLeft(point) {
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+		depth++
 	}
+	return depth
+}
 
-	if currentDepth >= node.Size {
-		return currentDepth
+// Traverse traverses the isolation tree and returns the path length for a data point: the number of edges
+// to the external node it reaches, plus the average path length of the points left unisolated in that node
+// when the tree was capped at its maximum depth
+func (node *IsolationTreeNode) Traverse(point []float64, currentDepth int) float64 {
+	if node == nil {
+		return float64(currentDepth)
 	}
 
 	if node.Left == nil && node.Right == nil {
-		return currentDepth + 1
+		return float64(currentDepth) + averagePathLength(node.Size)
 	}
 
-	if point[node.SplitFeature] < node.SplitValue {
+	if node.goesLeft(point) {
 		return node.Left.Traverse(point, currentDepth+1)
 	}
 	return node.Right.Traverse(point, currentDepth+1)
 }
 
-// averagePathLength returns the average path length for data points
+// averagePathLength returns the average path length of an unsuccessful search in a binary search tree of numDataPoints points
 func averagePathLength(numDataPoints int) float64 {
 	if numDataPoints > 2 {
 		return 2 * (math.Log(float64(numDataPoints-1)) + 0.5772156649 - float64(numDataPoints-1)/float64(numDataPoints))
 	}
-	return 1
+	if numDataPoints == 2 {
+		return 1
+	}
+	return 0
+}
+
+// LOF detects outliers with the local outlier factor, which compares the density around a point with the density
+// around its k nearest neighbors. Scores near 1 are inliers and scores well above 1 are outliers.
+type LOF struct {
+	Data       [][]float64
+	K          int
+	kDistances []float64 // Distance from each training point to its k-th nearest neighbor
+	densities  []float64 // Local reachability density of each training point
+}
+
+// minReachDistance keeps densities finite when a point has k duplicates
+const minReachDistance = 1e-10
+
+// Fit stores the training data and computes the k-distance and local reachability density of every point.
+// It needs k >= 1 and at least two points; k larger than len(data)-1 uses every other point as a neighbor.
+func (lof *LOF) Fit(data [][]float64, k int) error {
+	if k < 1 {
+		return fmt.Errorf("k must be at least 1, got %d", k)
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("LOF needs at least 2 points, got %d", len(data))
+	}
+	lof.Data = data
+	lof.K = k
+
+	neighbors := make([][]int, len(data))
+	lof.kDistances = make([]float64, len(data))
+	for i, point := range data {
+		neighbors[i] = lof.nearest(point, i)
+		lof.kDistances[i] = euclideanDistance(point, data[neighbors[i][len(neighbors[i])-1]])
+	}
+
+	lof.densities = make([]float64, len(data))
+	for i, point := range data {
+		lof.densities[i] = lof.density(point, neighbors[i])
+	}
+	return nil
+}
+
+// Score returns the local outlier factor of a point: the mean density of its k nearest training points
+// divided by its own density. A point from the training data is not counted as its own neighbor,
+// so it scores the same as in Fit.
+func (lof *LOF) Score(point []float64) float64 {
+	neighbors := lof.nearest(point, lof.indexOf(point))
+	sum := 0.0
+	for _, j := range neighbors {
+		sum += lof.densities[j]
+	}
+	return sum / float64(len(neighbors)) / lof.density(point, neighbors)
+}
+
+// density returns the local reachability density of a point from its neighbors: the inverse of its mean
+// reachability distance, where the reachability distance to a neighbor is at least that neighbor's k-distance
+func (lof *LOF) density(point []float64, neighbors []int) float64 {
+	sum := 0.0
+	for _, j := range neighbors {
+		sum += math.Max(lof.kDistances[j], euclideanDistance(point, lof.Data[j]))
+	}
+	return 1 / math.Max(sum/float64(len(neighbors)), minReachDistance)
+}
+
+// nearest returns the indices of the K training points closest to point, nearest first, skipping index exclude
+func (lof *LOF) nearest(point []float64, exclude int) []int {
+	indices := make([]int, 0, len(lof.Data))
+	distances := make([]float64, len(lof.Data))
+	for j, other := range lof.Data {
+		if j == exclude {
+			continue
+		}
+		distances[j] = euclideanDistance(point, other)
+		indices = append(indices, j)
+	}
+	sort.SliceStable(indices, func(a, b int) bool { return distances[indices[a]] < distances[indices[b]] })
+
+	k := lof.K
+	if k > len(indices) {
+		k = len(indices)
+	}
+	return indices[:k]
+}
+
+// indexOf returns the index of the first training point equal to point, or -1 if there is none
+func (lof *LOF) indexOf(point []float64) int {
+	for j, other := range lof.Data {
+		if euclideanDistance(point, other) == 0 {
+			return j
+		}
+	}
+	return -1
+}
+
+// euclideanDistance calculates the Euclidean distance between two points
+func euclideanDistance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		diff := a[i] - b[i]
+		sum += diff * diff
+	}
+	return math.Sqrt(sum)
+}
+
+// ScoreFile scores every row of a CSV file with the trained forest, reading one row at a time,
+// and writes one anomaly score per line to out. Malformed rows stop scoring with an error naming their line,
+// after the scores of the rows before them have been written.
+func (forest *IsolationForest) ScoreFile(filename string, out io.Writer) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.ReuseRecord = true
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	var point []float64
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		point = point[:0]
+		for j, value := range record {
+			num, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s: line %d, column %d: %w", filename, line, j+1, err)
+			}
+			point = append(point, num)
+		}
+
+		if _, err := fmt.Fprintln(writer, strconv.FormatFloat(forest.AnomalyScore(point), 'g', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
 }
 
 // LoadDataFromFile loads data from a CSV file
@@ -191,9 +649,13 @@ func main() {
 		{0, 0},
 	}
 
+	// Flag the 2% most anomalous training points
+	forest.FitThreshold(data, 0.02)
+
 	// Print anomaly scores
 	for _, point := range samplePoints {
 		anomalyScore := forest.AnomalyScore(point)
-		fmt.Printf("Anomaly score for point %v: %f\n", point, anomalyScore)
+		depths := forest.PathLengths(point)
+		fmt.Printf("Anomaly score for point %v: %f (anomaly: %v, depths: %v)\n", point, anomalyScore, forest.Predict(point), depths[:min(5, len(depths))])
 	}
 }
@@ -0,0 +1,355 @@
+package anomolyDetection
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// twoClusters returns a tight cluster around (0, 0) and a loose cluster around (10, 10)
+func twoClusters(rng *rand.Rand) [][]float64 {
+	var data [][]float64
+	for i := 0; i < 50; i++ {
+		data = append(data, []float64{rng.NormFloat64() * 0.2, rng.NormFloat64() * 0.2})
+	}
+	for i := 0; i < 50; i++ {
+		data = append(data, []float64{10 + rng.NormFloat64()*2, 10 + rng.NormFloat64()*2})
+	}
+	return data
+}
+
+func TestLOFScoresOutlierBetweenClusters(t *testing.T) {
+	data := twoClusters(rand.New(rand.NewSource(1)))
+	var lof LOF
+	if err := lof.Fit(data, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	outlier := lof.Score([]float64{4, 4})
+	if outlier <= 1.5 {
+		t.Errorf("outlier between the clusters scored %v, want well above 1", outlier)
+	}
+
+	// Training points of both densities score near 1, and a training point is not its own neighbor
+	for _, i := range []int{0, 60} {
+		if score := lof.Score(data[i]); score < 0.5 || score > 1.5 {
+			t.Errorf("training point %d scored %v, want near 1", i, score)
+		}
+	}
+}
+
+func TestLOFFitRejectsInvalidInput(t *testing.T) {
+	var lof LOF
+	if err := lof.Fit([][]float64{{0}, {1}, {2}}, 0); err == nil {
+		t.Error("expected an error for k = 0")
+	}
+	if err := lof.Fit([][]float64{{0}}, 1); err == nil {
+		t.Error("expected an error for a single point")
+	}
+	if err := lof.Fit([][]float64{{0}, {1}}, 5); err != nil {
+		t.Errorf("k larger than the data should be clamped, got %v", err)
+	}
+}
+
+// clusterWithOutliers returns n points of a dense cluster around the origin followed by the given far-away outliers
+func clusterWithOutliers(rng *rand.Rand, n int, outliers ...[]float64) [][]float64 {
+	var data [][]float64
+	for i := 0; i < n; i++ {
+		data = append(data, []float64{rng.NormFloat64(), rng.NormFloat64()})
+	}
+	return append(data, outliers...)
+}
+
+func TestSaveLoadPreservesScores(t *testing.T) {
+	data := clusterWithOutliers(rand.New(rand.NewSource(1)), 200, []float64{8, 8})
+	forest := NewIsolationForest(50, 10)
+	forest.SampleSize = 64
+	forest.Seed = 3
+	forest.Train(data)
+
+	var buf bytes.Buffer
+	if err := forest.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, point := range data {
+		if want, got := forest.AnomalyScore(point), loaded.AnomalyScore(point); want != got {
+			t.Fatalf("point %d scores %v after loading, want %v", i, got, want)
+		}
+	}
+}
+
+func TestUntrainedForestScoresZero(t *testing.T) {
+	forest := NewIsolationForest(10, 5)
+	if score := forest.AnomalyScore([]float64{1, 2}); score != 0 {
+		t.Errorf("untrained forest scored %v, want 0", score)
+	}
+}
+
+func TestPathLengthsHasOneEntryPerTree(t *testing.T) {
+	data := clusterWithOutliers(rand.New(rand.NewSource(1)), 100, []float64{8, 8})
+	for _, numTrees := range []int{1, 3, 25} {
+		forest := NewIsolationForest(numTrees, 8)
+		forest.Train(data)
+		depths := forest.PathLengths([]float64{8, 8})
+		if len(depths) != numTrees {
+			t.Errorf("got %d path lengths for %d trees", len(depths), numTrees)
+		}
+		for _, depth := range depths {
+			if depth < 0 || depth > 8 {
+				t.Errorf("path length %d outside [0, MaxTreeDepth]", depth)
+			}
+		}
+	}
+}
+
+func TestIsolationForestScoresOutlierAboveCluster(t *testing.T) {
+	data := clusterWithOutliers(rand.New(rand.NewSource(2)), 300, []float64{9, -9})
+	forest := NewIsolationForest(100, 10)
+	forest.Seed = 1
+	forest.Train(data)
+
+	outlier := forest.AnomalyScore([]float64{9, -9})
+	inlier := forest.AnomalyScore([]float64{0, 0})
+	if outlier < 0.65 || outlier-inlier < 0.2 {
+		t.Errorf("outlier scored %v and cluster center %v, want the outlier clearly higher", outlier, inlier)
+	}
+}
+
+func TestFitThresholdFlagsContamination(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	data := clusterWithOutliers(rng, 500)
+	forest := NewIsolationForest(100, 10)
+	forest.Train(data)
+	forest.FitThreshold(data, 0.05)
+
+	flagged := 0
+	for _, point := range data {
+		if forest.Predict(point) {
+			flagged++
+		}
+	}
+	if flagged < 20 || flagged > 30 {
+		t.Errorf("flagged %d of %d points, want about 5%%", flagged, len(data))
+	}
+
+	forest.FitThreshold(data, 0)
+	for _, point := range data {
+		if forest.Predict(point) {
+			t.Fatal("zero contamination flagged a point")
+		}
+	}
+}
+
+func TestSmallSampleSizeDetectsOutliers(t *testing.T) {
+	outliers := [][]float64{{10, 10}, {-10, 8}, {9, -11}}
+	data := clusterWithOutliers(rand.New(rand.NewSource(5)), 5000, outliers...)
+
+	forest := NewIsolationForest(50, 12)
+	forest.SampleSize = 64
+	forest.Train(data)
+
+	forest.FitThreshold(data, float64(len(outliers))/float64(len(data)))
+	for _, outlier := range outliers {
+		if !forest.Predict(outlier) {
+			t.Errorf("planted outlier %v scored %v, below the threshold %v", outlier, forest.AnomalyScore(outlier), forest.Threshold)
+		}
+	}
+}
+
+// BenchmarkTrainSampleSize compares training on subsamples of 64 points with training on all the data
+func BenchmarkTrainSampleSize(b *testing.B) {
+	data := clusterWithOutliers(rand.New(rand.NewSource(5)), 5000, []float64{10, 10})
+	for _, sampleSize := range []int{64, 0} {
+		name := "subsample"
+		if sampleSize == 0 {
+			name = "all"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				forest := NewIsolationForest(50, 12)
+				forest.SampleSize = sampleSize
+				forest.NumWorkers = 1
+				forest.Train(data)
+			}
+		})
+	}
+}
+
+func TestTrainIsIndependentOfWorkers(t *testing.T) {
+	data := clusterWithOutliers(rand.New(rand.NewSource(6)), 300, []float64{8, 8})
+	train := func(numWorkers int) *IsolationForest {
+		forest := NewIsolationForest(40, 8)
+		forest.SampleSize = 128
+		forest.Seed = 11
+		forest.NumWorkers = numWorkers
+		forest.Train(data)
+		return forest
+	}
+
+	sequential := train(1)
+	for _, numWorkers := range []int{2, 7} {
+		if !reflect.DeepEqual(sequential.Trees, train(numWorkers).Trees) {
+			t.Errorf("forest built with %d workers differs from the sequential one", numWorkers)
+		}
+	}
+}
+
+func TestExtendedForestScoresMoreIsotropically(t *testing.T) {
+	// Compare the spread of scores along an ellipse two standard deviations around a diagonal cluster
+	spread := func(scores []float64) float64 {
+		mean := 0.0
+		for _, score := range scores {
+			mean += score
+		}
+		mean /= float64(len(scores))
+		variance := 0.0
+		for _, score := range scores {
+			variance += (score - mean) * (score - mean)
+		}
+		return math.Sqrt(variance / float64(len(scores)))
+	}
+
+	for seed := int64(0); seed < 3; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		var data [][]float64
+		for i := 0; i < 500; i++ {
+			along, across := rng.NormFloat64()*3, rng.NormFloat64()*0.5
+			data = append(data, []float64{along + across, along - across})
+		}
+		var ellipse [][]float64
+		for k := 0; k < 32; k++ {
+			angle := 2 * math.Pi * float64(k) / 32
+			along, across := 6*math.Cos(angle), math.Sin(angle)
+			ellipse = append(ellipse, []float64{along + across, along - across})
+		}
+
+		standard := NewIsolationForest(200, 10)
+		standard.Seed = seed
+		standard.Train(data)
+		extended := NewExtendedIsolationForest(200, 10)
+		extended.Seed = seed
+		extended.Train(data)
+
+		if standardSpread, extendedSpread := spread(standard.Scores(ellipse)), spread(extended.Scores(ellipse)); extendedSpread >= standardSpread {
+			t.Errorf("seed %d: extended forest scores spread %v along the ellipse, not less than the standard forest's %v", seed, extendedSpread, standardSpread)
+		}
+	}
+}
+
+func TestConstantFeatureStillDiscriminates(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	var data [][]float64
+	for i := 0; i < 300; i++ {
+		data = append(data, []float64{rng.NormFloat64(), 5, rng.NormFloat64()})
+	}
+	data = append(data, []float64{8, 5, -8})
+	forest := NewIsolationForest(100, 10)
+	forest.Train(data)
+
+	outlier := forest.AnomalyScore([]float64{8, 5, -8})
+	inlier := forest.AnomalyScore([]float64{0, 5, 0})
+	if outlier-inlier < 0.2 {
+		t.Errorf("outlier scored %v and cluster center %v with a constant column, want the outlier clearly higher", outlier, inlier)
+	}
+	for _, depth := range forest.PathLengths([]float64{0, 5, 0}) {
+		if depth == 0 {
+			t.Fatal("a tree did not split the data")
+		}
+	}
+}
+
+func TestCategoricalAndNumericFeatures(t *testing.T) {
+	// Category 0 or 1 with a numeric value around 0, except one record with a rare category
+	rng := rand.New(rand.NewSource(8))
+	var data [][]float64
+	for i := 0; i < 300; i++ {
+		data = append(data, []float64{float64(i % 2), rng.NormFloat64()})
+	}
+	rare := []float64{7, 0}
+	data = append(data, rare)
+
+	forest := NewIsolationForest(100, 10)
+	forest.CategoricalFeatures = []bool{true, false}
+	forest.Train(data)
+
+	common := forest.AnomalyScore([]float64{1, 0})
+	if score := forest.AnomalyScore(rare); score-common < 0.15 {
+		t.Errorf("rare category scored %v and a common record %v, want the rare category clearly higher", score, common)
+	}
+	if score := forest.AnomalyScore([]float64{0, 6}); score-common < 0.15 {
+		t.Errorf("extreme numeric value scored %v and a common record %v, want the extreme value clearly higher", score, common)
+	}
+}
+
+func TestRankScoresSpansZeroToOne(t *testing.T) {
+	data := clusterWithOutliers(rand.New(rand.NewSource(9)), 200, []float64{12, 12})
+	forest := NewIsolationForest(100, 10)
+	forest.Train(data)
+
+	ranks := forest.RankScores(data)
+	scores := forest.Scores(data)
+	least := 0
+	for i := range scores {
+		if scores[i] < scores[least] {
+			least = i
+		}
+	}
+	if ranks[len(data)-1] != 1 {
+		t.Errorf("most anomalous point ranked %v, want 1", ranks[len(data)-1])
+	}
+	if ranks[least] > 0.01 {
+		t.Errorf("least anomalous point ranked %v, want about 0", ranks[least])
+	}
+	for i, rank := range ranks {
+		if rank < 0 || rank > 1 {
+			t.Errorf("rank %v of point %d outside [0, 1]", rank, i)
+		}
+	}
+}
+
+func TestScoreFileStreamsScoresAndReportsBadLine(t *testing.T) {
+	forest := NewIsolationForest(50, 8)
+	forest.Train(clusterWithOutliers(rand.New(rand.NewSource(10)), 200))
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.csv")
+	if err := os.WriteFile(good, []byte("0,0\n1,-1\n9,9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := forest.ScoreFile(good, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(out.String())
+	if len(lines) != 3 {
+		t.Fatalf("got %d scores, want 3", len(lines))
+	}
+	for i, point := range [][]float64{{0, 0}, {1, -1}, {9, 9}} {
+		if score, err := strconv.ParseFloat(lines[i], 64); err != nil || score != forest.AnomalyScore(point) {
+			t.Errorf("score %d = %q, want %v", i, lines[i], forest.AnomalyScore(point))
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	if err := os.WriteFile(bad, []byte("0,0\n1,-1\n2,oops\n3,3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err := forest.ScoreFile(bad, &out)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("error %v, want one naming line 3", err)
+	}
+	if written := strings.Fields(out.String()); len(written) != 2 {
+		t.Errorf("wrote %d scores before the bad row, want 2", len(written))
+	}
+}
@@ -1,14 +1,27 @@
 package associationRule
 
 import(
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-// Itemset represents a set of items
+// Itemset represents a set of items, stored in sorted order so that Equal and Hash do not depend on item order
 type Itemset []string
 
+// NewItemset creates an itemset from the given items, sorting them and dropping duplicates
+func NewItemset(items ...string) Itemset {
+	return Itemset(normalize(items))
+}
+
 // Equal checks if two Itemsets are equal
 func (s Itemset) Equal(other Itemset) bool {
 	if len(s) != len(other) {
@@ -30,6 +43,30 @@ func (s Itemset) Hash() string {
 // Transaction represents a transaction in the dataset
 type Transaction []string
 
+// normalize returns a sorted copy of items without duplicates
+func normalize(items []string) []string {
+	sorted := make([]string, len(items))
+	copy(sorted, items)
+	sort.Strings(sorted)
+
+	unique := sorted[:0]
+	for i, item := range sorted {
+		if i == 0 || item != sorted[i-1] {
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+// normalizeTransactions sorts and deduplicates the items of every transaction, so each item counts once towards support
+func normalizeTransactions(transactions []Transaction) []Transaction {
+	normalized := make([]Transaction, len(transactions))
+	for i, transaction := range transactions {
+		normalized[i] = Transaction(normalize(transaction))
+	}
+	return normalized
+}
+
 // AssociationRule represents an association rule
 type AssociationRule struct {
 	Antecedent Itemset
@@ -37,33 +74,51 @@ type AssociationRule struct {
 	Support    float64
 	Confidence float64
 	Lift       float64
+	Conviction float64 // How much more often the rule would be wrong if A and B were independent; +Inf when Confidence is 1
+	Leverage   float64 // Support of A and B together minus the support expected if they were independent
 }
 
 // AssociationRuleSet represents a set of association rules
 type AssociationRuleSet []AssociationRule
 
-// GenerateAssociationRules generates association rules from the given transactions
-func GenerateAssociationRules(transactions []Transaction, minSupport, minConfidence float64) AssociationRuleSet {
-	// Step 1: Find frequent itemsets
-	frequentItemsets := findFrequentItemsets(transactions, minSupport)
+// GenerateAssociationRules generates association rules from the given transactions.
+// Rules come from frequent itemsets of at most maxItemsetSize items; 0 means no limit.
+// With singleConsequent, only rules with exactly one consequent item are generated.
+func GenerateAssociationRules(transactions []Transaction, minSupport, minConfidence float64, maxItemsetSize int, singleConsequent bool) AssociationRuleSet {
+	transactions = normalizeTransactions(transactions)
+
+	// Step 1: Find frequent itemsets, caching the support of every candidate by its hash
+	supports := make(map[string]float64)
+	frequentItemsets := findFrequentItemsets(transactions, minSupport, maxItemsetSize, supports)
 
 	// Step 2: Generate association rules from frequent itemsets
 	rules := make(AssociationRuleSet, 0)
 	for _, itemset := range frequentItemsets {
 		if len(itemset) > 1 {
-			subsets := generateSubsets(itemset)
+			var subsets []Itemset
+			if singleConsequent {
+				subsets = generateAntecedents(itemset)
+			} else {
+				subsets = generateSubsets(itemset)
+			}
 			for _, subset := range subsets {
 				antecedent := subset
 				consequent := getDifference(itemset, subset)
+				if len(antecedent) == 0 || len(consequent) == 0 {
+					continue
+				}
 				rule := AssociationRule{
 					Antecedent: antecedent,
 					Consequent: consequent,
-					Support:    calculateSupport(itemset, transactions),
+					Support:    cachedSupport(itemset, transactions, supports),
 				}
 				if rule.Support >= minSupport {
-					rule.Confidence = calculateConfidence(antecedent, consequent, transactions)
+					rule.Confidence = calculateConfidence(antecedent, consequent, transactions, supports)
 					if rule.Confidence >= minConfidence {
-						rule.Lift = calculateLift(rule.Confidence, calculateSupport(consequent, transactions))
+						consequentSupport := cachedSupport(consequent, transactions, supports)
+						rule.Lift = calculateLift(rule.Confidence, consequentSupport)
+						rule.Conviction = calculateConviction(rule.Confidence, consequentSupport)
+						rule.Leverage = rule.Support - cachedSupport(antecedent, transactions, supports)*consequentSupport
 						rules = append(rules, rule)
 					}
 				}
@@ -73,31 +128,234 @@ func GenerateAssociationRules(transactions []Transaction, minSupport, minConfide
 	return rules
 }
 
-// findFrequentItemsets finds frequent itemsets from transactions using Apriori algorithm
-func findFrequentItemsets(transactions []Transaction, minSupport float64) []Itemset {
+// GenerateAssociationRulesByCount generates association rules from itemsets that occur in at least minCount transactions.
+// Since supports share the denominator len(transactions), the threshold is exact rather than subject to rounding.
+func GenerateAssociationRulesByCount(transactions []Transaction, minCount int, minConfidence float64, maxItemsetSize int, singleConsequent bool) AssociationRuleSet {
+	if len(transactions) == 0 {
+		return make(AssociationRuleSet, 0)
+	}
+	minSupport := float64(minCount) / float64(len(transactions))
+	return GenerateAssociationRules(transactions, minSupport, minConfidence, maxItemsetSize, singleConsequent)
+}
+
+// FilterByLength returns the rules whose antecedent and consequent lengths lie within the given bounds.
+// A maximum of 0 means no upper bound.
+func (rules AssociationRuleSet) FilterByLength(minAntecedent, maxAntecedent, minConsequent, maxConsequent int) AssociationRuleSet {
+	filtered := make(AssociationRuleSet, 0)
+	for _, rule := range rules {
+		if len(rule.Antecedent) < minAntecedent || (maxAntecedent > 0 && len(rule.Antecedent) > maxAntecedent) {
+			continue
+		}
+		if len(rule.Consequent) < minConsequent || (maxConsequent > 0 && len(rule.Consequent) > maxConsequent) {
+			continue
+		}
+		filtered = append(filtered, rule)
+	}
+	return filtered
+}
+
+// metric returns the value of the named metric of a rule
+func (rule AssociationRule) metric(name string) (float64, error) {
+	switch name {
+	case "lift":
+		return rule.Lift, nil
+	case "confidence":
+		return rule.Confidence, nil
+	case "support":
+		return rule.Support, nil
+	case "conviction":
+		return rule.Conviction, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", name)
+}
+
+// TopN returns the n rules with the highest value of the metric by ("lift", "confidence", "support" or "conviction"),
+// in decreasing order. Ties are broken by support, then confidence, then the antecedent and consequent.
+// It returns an error for negative n.
+func (rules AssociationRuleSet) TopN(n int, by string) (AssociationRuleSet, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("n must not be negative, got %d", n)
+	}
+	if _, err := (AssociationRule{}).metric(by); err != nil {
+		return nil, err
+	}
+
+	sorted := make(AssociationRuleSet, len(rules))
+	copy(sorted, rules)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		valueA, _ := a.metric(by)
+		valueB, _ := b.metric(by)
+		if valueA != valueB {
+			return valueA > valueB
+		}
+		if a.Support != b.Support {
+			return a.Support > b.Support
+		}
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		if a.Antecedent.Hash() != b.Antecedent.Hash() {
+			return a.Antecedent.Hash() < b.Antecedent.Hash()
+		}
+		return a.Consequent.Hash() < b.Consequent.Hash()
+	})
+
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted, nil
+}
+
+// WriteCSV writes the rules as CSV with a header row and the columns antecedent, consequent, support, confidence and lift.
+// Itemsets are written as their items joined by commas.
+func (rules AssociationRuleSet) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"antecedent", "consequent", "support", "confidence", "lift"}); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		record := []string{
+			rule.Antecedent.Hash(),
+			rule.Consequent.Hash(),
+			strconv.FormatFloat(rule.Support, 'g', -1, 64),
+			strconv.FormatFloat(rule.Confidence, 'g', -1, 64),
+			strconv.FormatFloat(rule.Lift, 'g', -1, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ruleJSON is the JSON form of an AssociationRule. JSON has no infinity, so an infinite conviction is written as null.
+type ruleJSON struct {
+	Antecedent []string `json:"antecedent"`
+	Consequent []string `json:"consequent"`
+	Support    float64  `json:"support"`
+	Confidence float64  `json:"confidence"`
+	Lift       float64  `json:"lift"`
+	Conviction *float64 `json:"conviction"`
+	Leverage   float64  `json:"leverage"`
+}
+
+// WriteJSON writes the rules as a JSON array, with itemsets as arrays of strings
+func (rules AssociationRuleSet) WriteJSON(w io.Writer) error {
+	encoded := make([]ruleJSON, len(rules))
+	for i, rule := range rules {
+		encoded[i] = ruleJSON{
+			Antecedent: rule.Antecedent,
+			Consequent: rule.Consequent,
+			Support:    rule.Support,
+			Confidence: rule.Confidence,
+			Lift:       rule.Lift,
+			Leverage:   rule.Leverage,
+		}
+		if !math.IsInf(rule.Conviction, 1) {
+			conviction := rule.Conviction
+			encoded[i].Conviction = &conviction
+		}
+	}
+	return json.NewEncoder(w).Encode(encoded)
+}
+
+// ReadJSON reads rules written by WriteJSON
+func ReadJSON(r io.Reader) (AssociationRuleSet, error) {
+	var encoded []ruleJSON
+	if err := json.NewDecoder(r).Decode(&encoded); err != nil {
+		return nil, err
+	}
+	rules := make(AssociationRuleSet, len(encoded))
+	for i, rule := range encoded {
+		rules[i] = AssociationRule{
+			Antecedent: rule.Antecedent,
+			Consequent: rule.Consequent,
+			Support:    rule.Support,
+			Confidence: rule.Confidence,
+			Lift:       rule.Lift,
+			Conviction: math.Inf(1),
+			Leverage:   rule.Leverage,
+		}
+		if rule.Conviction != nil {
+			rules[i].Conviction = *rule.Conviction
+		}
+	}
+	return rules, nil
+}
+
+// findFrequentItemsets finds frequent itemsets from transactions using Apriori algorithm.
+// Each level joins the frequent k-itemsets into (k+1)-candidates until no candidate is frequent
+// or the itemsets reach maxItemsetSize items (0 means no limit). Items within every itemset are kept sorted.
+// The support of every candidate is stored in supports.
+func findFrequentItemsets(transactions []Transaction, minSupport float64, maxItemsetSize int, supports map[string]float64) []Itemset {
 	frequentItemsets := make([]Itemset, 0)
-	itemsetCount := make(map[string]int)
 	candidates := generateInitialCandidates(transactions)
 
-	for _, transaction := range transactions {
-		for _, candidate := range candidates {
-			if containsItem(transaction, candidate) {
-				itemsetCount[candidate.Hash()]++
+	for size := 1; len(candidates) > 0; size++ {
+		candidateSupports := countSupports(candidates, transactions)
+
+		frequent := make([]Itemset, 0)
+		for i, candidate := range candidates {
+			support := candidateSupports[i]
+			supports[candidate.Hash()] = support
+			if support >= minSupport {
+				frequent = append(frequent, candidate)
 			}
 		}
+		frequentItemsets = append(frequentItemsets, frequent...)
+		if maxItemsetSize > 0 && size >= maxItemsetSize {
+			break
+		}
+		candidates = generateCandidates(frequent)
+	}
+	return frequentItemsets
+}
+
+// generateCandidates joins pairs of frequent k-itemsets that share their first k-1 items into (k+1)-candidates,
+// dropping any candidate with a k-subset that is not frequent
+func generateCandidates(frequent []Itemset) []Itemset {
+	isFrequent := make(map[string]bool)
+	for _, itemset := range frequent {
+		isFrequent[itemset.Hash()] = true
 	}
 
-	for itemsetStr, count := range itemsetCount {
-		itemset := strings.Split(itemsetStr, ",")
-		support := float64(count) / float64(len(transactions))
-		if support >= minSupport {
-			frequentItemsets = append(frequentItemsets, itemset)
+	candidates := make([]Itemset, 0)
+	for i, a := range frequent {
+		for _, b := range frequent[i+1:] {
+			k := len(a)
+			if !a[:k-1].Equal(b[:k-1]) {
+				continue
+			}
+			candidate := make(Itemset, 0, k+1)
+			candidate = append(candidate, a...)
+			if a[k-1] < b[k-1] {
+				candidate = append(candidate, b[k-1])
+			} else {
+				candidate = append(candidate[:k-1], b[k-1], a[k-1])
+			}
+
+			// Every subset of a frequent itemset is frequent, so check the subsets without one item
+			allFrequent := true
+			for skip := range candidate {
+				subset := make(Itemset, 0, k)
+				subset = append(subset, candidate[:skip]...)
+				subset = append(subset, candidate[skip+1:]...)
+				if !isFrequent[subset.Hash()] {
+					allFrequent = false
+					break
+				}
+			}
+			if allFrequent {
+				candidates = append(candidates, candidate)
+			}
 		}
 	}
-	return frequentItemsets
+	return candidates
 }
 
-// generateInitialCandidates generates initial candidates from transactions
+// generateInitialCandidates generates the single-item candidates from transactions in sorted order
 func generateInitialCandidates(transactions []Transaction) []Itemset {
 	candidates := make([]Itemset, 0)
 	itemSet := make(map[string]bool)
@@ -108,7 +366,12 @@ func generateInitialCandidates(transactions []Transaction) []Itemset {
 		}
 	}
 
+	items := make([]string, 0, len(itemSet))
 	for item := range itemSet {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	for _, item := range items {
 		candidates = append(candidates, Itemset{item})
 	}
 	return candidates
@@ -131,7 +394,7 @@ func containsItem(transaction Transaction, itemset Itemset) bool {
 	return true
 }
 
-// generateSubsets generates all possible subsets of an itemset
+// generateSubsets generates all non-empty proper subsets of an itemset, the possible antecedents of its rules
 func generateSubsets(itemset Itemset) []Itemset {
 	subsets := make([]Itemset, 0)
 	generateSubsetsHelper(itemset, 0, &[]string{}, &subsets)
@@ -141,7 +404,12 @@ func generateSubsets(itemset Itemset) []Itemset {
 // generateSubsetsHelper is a helper function for generating subsets recursively
 func generateSubsetsHelper(itemset Itemset, index int, current *[]string, subsets *[]Itemset) {
 	if index == len(itemset) {
-		*subsets = append(*subsets, *current)
+		// Copy current, which is reused by the other branches of the recursion
+		if len(*current) > 0 && len(*current) < len(itemset) {
+			subset := make(Itemset, len(*current))
+			copy(subset, *current)
+			*subsets = append(*subsets, subset)
+		}
 		return
 	}
 	*current = append(*current, itemset[index])
@@ -150,6 +418,19 @@ func generateSubsetsHelper(itemset Itemset, index int, current *[]string, subset
 	generateSubsetsHelper(itemset, index+1, current, subsets)
 }
 
+// generateAntecedents generates the subsets of an itemset that leave out exactly one item,
+// the antecedents of its rules with a single consequent item
+func generateAntecedents(itemset Itemset) []Itemset {
+	antecedents := make([]Itemset, 0, len(itemset))
+	for skip := range itemset {
+		antecedent := make(Itemset, 0, len(itemset)-1)
+		antecedent = append(antecedent, itemset[:skip]...)
+		antecedent = append(antecedent, itemset[skip+1:]...)
+		antecedents = append(antecedents, antecedent)
+	}
+	return antecedents
+}
+
 // getDifference returns the difference of two itemsets
 func getDifference(itemset, subset Itemset) Itemset {
 	difference := make(Itemset, 0)
@@ -168,6 +449,44 @@ func getDifference(itemset, subset Itemset) Itemset {
 	return difference
 }
 
+// countSupports calculates the support of every candidate, sharing the candidates among one worker per CPU
+func countSupports(candidates []Itemset, transactions []Transaction) []float64 {
+	supports := make([]float64, len(candidates))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := runtime.NumCPU()
+	if workers > len(candidates) {
+		workers = len(candidates)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				supports[i] = calculateSupport(candidates[i], transactions)
+			}
+		}()
+	}
+
+	for i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return supports
+}
+
+// cachedSupport returns the support of a sorted itemset from supports, calculating and storing it on a miss
+func cachedSupport(itemset Itemset, transactions []Transaction, supports map[string]float64) float64 {
+	if support, ok := supports[itemset.Hash()]; ok {
+		return support
+	}
+	support := calculateSupport(itemset, transactions)
+	supports[itemset.Hash()] = support
+	return support
+}
+
 // calculateSupport calculates the support of an itemset in transactions
 func calculateSupport(itemset Itemset, transactions []Transaction) float64 {
 	count := 0
@@ -179,10 +498,17 @@ func calculateSupport(itemset Itemset, transactions []Transaction) float64 {
 	return float64(count) / float64(len(transactions))
 }
 
-// calculateConfidence calculates the confidence of a rule
-func calculateConfidence(antecedent, consequent Itemset, transactions []Transaction) float64 {
-	combined := append(antecedent, consequent...)
-	return calculateSupport(combined, transactions) / calculateSupport(antecedent, transactions)
+// calculateConfidence calculates the confidence of a rule, looking supports up in the cache
+func calculateConfidence(antecedent, consequent Itemset, transactions []Transaction, supports map[string]float64) float64 {
+	// Build combined in a new slice so appending can never write into the antecedent's backing array
+	combined := make(Itemset, 0, len(antecedent)+len(consequent))
+	combined = append(combined, antecedent...)
+	combined = append(combined, consequent...)
+	antecedentSupport := cachedSupport(antecedent, transactions, supports)
+	if antecedentSupport == 0 {
+		return 0
+	}
+	return cachedSupport(NewItemset(combined...), transactions, supports) / antecedentSupport
 }
 
 // calculateLift calculates the lift of a rule
@@ -190,6 +516,57 @@ func calculateLift(confidence, consequentSupport float64) float64 {
 	return confidence / consequentSupport
 }
 
+// calculateConviction calculates the conviction of a rule, which is infinite for a rule that always holds
+func calculateConviction(confidence, consequentSupport float64) float64 {
+	if confidence >= 1 {
+		return math.Inf(1)
+	}
+	return (1 - consequentSupport) / (1 - confidence)
+}
+
+// ItemSimilarity computes the cosine similarity between the occurrence vectors of every pair of items
+// that appear together in at least one transaction. The result is symmetric, pairs that never
+// co-occur are omitted and have a similarity of 0.
+func ItemSimilarity(transactions []Transaction) map[string]map[string]float64 {
+	itemCount := make(map[string]int)
+	pairCount := make(map[string]map[string]int)
+
+	for _, transaction := range transactions {
+		// Count each item once per transaction
+		seen := make(map[string]bool)
+		items := make([]string, 0, len(transaction))
+		for _, item := range transaction {
+			if !seen[item] {
+				seen[item] = true
+				items = append(items, item)
+			}
+		}
+
+		for i, a := range items {
+			itemCount[a]++
+			for _, b := range items[i+1:] {
+				if pairCount[a] == nil {
+					pairCount[a] = make(map[string]int)
+				}
+				if pairCount[b] == nil {
+					pairCount[b] = make(map[string]int)
+				}
+				pairCount[a][b]++
+				pairCount[b][a]++
+			}
+		}
+	}
+
+	similarity := make(map[string]map[string]float64)
+	for a, counts := range pairCount {
+		similarity[a] = make(map[string]float64)
+		for b, count := range counts {
+			similarity[a][b] = float64(count) / math.Sqrt(float64(itemCount[a]*itemCount[b]))
+		}
+	}
+	return similarity
+}
+
 func main() {
 	// Sample transactions
 	transactions := []Transaction{
@@ -200,21 +577,35 @@ func main() {
 		{"bread", "milk", "diaper", "cola"},
 	}
 
-	// Minimum support and confidence thresholds
+	// Minimum support and confidence thresholds, and the largest itemset to mine
 	minSupport := 0.4
 	minConfidence := 0.6
+	maxItemsetSize := 3
 
-	// Generate association rules
-	rules := GenerateAssociationRules(transactions, minSupport, minConfidence)
+	// Generate association rules with a single item as consequent and at most two antecedent items
+	rules := GenerateAssociationRules(transactions, minSupport, minConfidence, maxItemsetSize, true).FilterByLength(1, 2, 1, 1)
 
-	// Sort rules by lift
-	sort.Slice(rules, func(i, j int) bool {
-		return rules[i].Lift > rules[j].Lift
-	})
+	// Keep the ten rules with the highest lift
+	rules, err := rules.TopN(10, "lift")
+	if err != nil {
+		fmt.Println("Error ranking rules:", err)
+		return
+	}
 
 	// Print association rules
 	fmt.Println("Association Rules:")
 	for _, rule := range rules {
-		fmt.Printf("%v -> %v (Support: %.2f, Confidence: %.2f, Lift: %.2f)\n", rule.Antecedent, rule.Consequent, rule.Support, rule.Confidence, rule.Lift)
+		fmt.Printf("%v -> %v (Support: %.2f, Confidence: %.2f, Lift: %.2f, Conviction: %.2f, Leverage: %.2f)\n", rule.Antecedent, rule.Consequent, rule.Support, rule.Confidence, rule.Lift, rule.Conviction, rule.Leverage)
+	}
+
+	// Export the rules for spreadsheets
+	if err = rules.WriteCSV(os.Stdout); err != nil {
+		fmt.Println("Error writing rules:", err)
+	}
+
+	// Print item-item similarities
+	fmt.Println("Item Similarity:")
+	for item, similar := range ItemSimilarity(transactions) {
+		fmt.Printf("%s: %v\n", item, similar)
 	}
 }
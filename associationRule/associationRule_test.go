@@ -0,0 +1,306 @@
+package associationRule
+
+import(
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+)
+
+// sampleTransactions returns the transactions of the package demo
+func sampleTransactions() []Transaction {
+	return []Transaction{
+		{"bread", "milk"},
+		{"bread", "diaper", "beer", "egg"},
+		{"milk", "diaper", "beer", "cola"},
+		{"bread", "milk", "diaper", "beer"},
+		{"bread", "milk", "diaper", "cola"},
+	}
+}
+
+func TestTopN(t *testing.T) {
+	rules := GenerateAssociationRules(sampleTransactions(), 0.4, 0.6, 3, true)
+
+	top, err := rules.TopN(3, "lift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 3 {
+		t.Fatalf("got %d rules, want 3", len(top))
+	}
+	for i := 1; i < len(top); i++ {
+		if top[i].Lift > top[i-1].Lift {
+			t.Errorf("rules not in decreasing lift: %v before %v", top[i-1].Lift, top[i].Lift)
+		}
+	}
+	for _, rule := range rules {
+		if rule.Lift > top[0].Lift {
+			t.Errorf("rule with lift %v missing from the top rules", rule.Lift)
+		}
+	}
+
+	if all, err := rules.TopN(len(rules)+5, "lift"); err != nil || len(all) != len(rules) {
+		t.Errorf("TopN beyond the rule count returned %d rules, %v", len(all), err)
+	}
+	if _, err := rules.TopN(-1, "lift"); err == nil {
+		t.Error("expected an error for negative n")
+	}
+	if _, err := rules.TopN(3, "novelty"); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+}
+
+// findRule returns the rule with the given sides, or nil if there is none
+func findRule(rules AssociationRuleSet, antecedent, consequent Itemset) *AssociationRule {
+	for i := range rules {
+		if rules[i].Antecedent.Equal(antecedent) && rules[i].Consequent.Equal(consequent) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func TestGenerateAssociationRulesFindsMultiItemRules(t *testing.T) {
+	rules := GenerateAssociationRules(sampleTransactions(), 0.4, 0.6, 0, false)
+
+	if findRule(rules, NewItemset("beer"), NewItemset("diaper")) == nil {
+		t.Error("missing rule {beer} -> {diaper}")
+	}
+	// beer, bread and diaper occur together in 2 of the 5 transactions
+	if findRule(rules, NewItemset("beer", "bread"), NewItemset("diaper")) == nil {
+		t.Error("missing rule {beer, bread} -> {diaper} from a frequent 3-itemset")
+	}
+}
+
+func TestCalculateConfidenceLeavesAntecedentUnchanged(t *testing.T) {
+	transactions := normalizeTransactions(sampleTransactions())
+	// Spare capacity lets a plain append write into the antecedent's backing array
+	backing := make([]string, 1, 4)
+	backing[0] = "beer"
+	antecedent := Itemset(backing)
+	extra := antecedent[:2]
+	extra[1] = "sentinel"
+
+	calculateConfidence(antecedent, NewItemset("diaper"), transactions, make(map[string]float64))
+
+	if len(antecedent) != 1 || antecedent[0] != "beer" {
+		t.Errorf("antecedent changed to %v", antecedent)
+	}
+	if extra[1] != "sentinel" {
+		t.Errorf("backing array of the antecedent overwritten with %q", extra[1])
+	}
+}
+
+func TestConvictionAndLeverage(t *testing.T) {
+	rules := GenerateAssociationRules(sampleTransactions(), 0.6, 0.7, 0, false)
+
+	// beer occurs in 3 of 5 transactions, diaper in 4 and both in 3
+	beerDiaper := findRule(rules, NewItemset("beer"), NewItemset("diaper"))
+	if beerDiaper == nil {
+		t.Fatal("missing rule {beer} -> {diaper}")
+	}
+	if !math.IsInf(beerDiaper.Conviction, 1) {
+		t.Errorf("conviction of a rule with confidence 1 = %v, want +Inf", beerDiaper.Conviction)
+	}
+	if math.Abs(beerDiaper.Leverage-0.12) > 1e-9 {
+		t.Errorf("leverage = %v, want 0.6 - 0.6*0.8 = 0.12", beerDiaper.Leverage)
+	}
+
+	diaperBeer := findRule(rules, NewItemset("diaper"), NewItemset("beer"))
+	if diaperBeer == nil {
+		t.Fatal("missing rule {diaper} -> {beer}")
+	}
+	if math.Abs(diaperBeer.Conviction-1.6) > 1e-9 {
+		t.Errorf("conviction = %v, want (1-0.6)/(1-0.75) = 1.6", diaperBeer.Conviction)
+	}
+	if math.Abs(diaperBeer.Leverage-0.12) > 1e-9 {
+		t.Errorf("leverage = %v, want 0.12", diaperBeer.Leverage)
+	}
+}
+
+func TestMaxItemsetSizeAndFilterByLength(t *testing.T) {
+	transactions := sampleTransactions()
+	if capped := GenerateAssociationRules(transactions, 0.4, 0.5, 2, false); len(capped) == 0 {
+		t.Fatal("no rules generated")
+	} else {
+		for _, rule := range capped {
+			if size := len(rule.Antecedent) + len(rule.Consequent); size > 2 {
+				t.Errorf("rule %v -> %v has %d items, want at most 2", rule.Antecedent, rule.Consequent, size)
+			}
+		}
+	}
+
+	rules := GenerateAssociationRules(transactions, 0.4, 0.5, 0, false)
+	filtered := rules.FilterByLength(2, 3, 1, 1)
+	if len(filtered) == 0 {
+		t.Fatal("no rules with a 2-item antecedent")
+	}
+	for _, rule := range filtered {
+		if len(rule.Antecedent) < 2 || len(rule.Antecedent) > 3 || len(rule.Consequent) != 1 {
+			t.Errorf("rule %v -> %v outside the length bounds", rule.Antecedent, rule.Consequent)
+		}
+	}
+}
+
+func TestSupportsIgnoreItemOrderAndDuplicates(t *testing.T) {
+	if itemset := NewItemset("milk", "bread", "milk"); !itemset.Equal(Itemset{"bread", "milk"}) {
+		t.Errorf("NewItemset = %v, want [bread milk]", itemset)
+	}
+
+	clean := []Transaction{{"bread", "milk"}, {"beer", "diaper"}, {"bread", "diaper", "milk"}}
+	messy := []Transaction{{"milk", "bread", "milk"}, {"diaper", "beer", "beer"}, {"milk", "diaper", "bread", "bread"}}
+
+	want := GenerateAssociationRules(clean, 0.3, 0.5, 0, false)
+	got := GenerateAssociationRules(messy, 0.3, 0.5, 0, false)
+	if len(got) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(got), len(want))
+	}
+	for _, rule := range want {
+		match := findRule(got, rule.Antecedent, rule.Consequent)
+		if match == nil {
+			t.Errorf("missing rule %v -> %v", rule.Antecedent, rule.Consequent)
+			continue
+		}
+		if match.Support != rule.Support || match.Confidence != rule.Confidence {
+			t.Errorf("rule %v -> %v has support %v and confidence %v, want %v and %v",
+				rule.Antecedent, rule.Consequent, match.Support, match.Confidence, rule.Support, rule.Confidence)
+		}
+	}
+}
+
+func TestCachedSupportsMatchDirectCounts(t *testing.T) {
+	transactions := normalizeTransactions(sampleTransactions())
+	supports := make(map[string]float64)
+	itemsets := findFrequentItemsets(transactions, 0.2, 0, supports)
+
+	counted := countSupports(itemsets, transactions)
+	for i, itemset := range itemsets {
+		want := calculateSupport(itemset, transactions)
+		if counted[i] != want {
+			t.Errorf("countSupports(%v) = %v, want %v", itemset, counted[i], want)
+		}
+		if got := cachedSupport(itemset, transactions, supports); got != want {
+			t.Errorf("cachedSupport(%v) = %v, want %v", itemset, got, want)
+		}
+	}
+
+	// A miss is counted and stored
+	missing := NewItemset("beer", "cola", "egg")
+	if got := cachedSupport(missing, transactions, supports); got != 0 {
+		t.Errorf("cachedSupport(%v) = %v, want 0", missing, got)
+	}
+	if _, ok := supports[missing.Hash()]; !ok {
+		t.Error("support of a missed itemset not stored")
+	}
+}
+
+func TestSingleConsequentRules(t *testing.T) {
+	rules := GenerateAssociationRules(sampleTransactions(), 0.4, 0.5, 0, true)
+	if len(rules) == 0 {
+		t.Fatal("no rules generated")
+	}
+	for _, rule := range rules {
+		if len(rule.Consequent) != 1 {
+			t.Errorf("rule %v -> %v has %d consequent items, want 1", rule.Antecedent, rule.Consequent, len(rule.Consequent))
+		}
+	}
+	if findRule(rules, NewItemset("beer", "bread"), NewItemset("diaper")) == nil {
+		t.Error("missing rule {beer, bread} -> {diaper}")
+	}
+}
+
+func TestRulesHaveNoEmptySide(t *testing.T) {
+	rules := GenerateAssociationRules(sampleTransactions(), 0.2, 0, 0, false)
+	if len(rules) == 0 {
+		t.Fatal("no rules generated")
+	}
+	for _, rule := range rules {
+		if len(rule.Antecedent) == 0 || len(rule.Consequent) == 0 {
+			t.Errorf("rule %v -> %v has an empty side", rule.Antecedent, rule.Consequent)
+		}
+		if math.IsNaN(rule.Confidence) {
+			t.Errorf("rule %v -> %v has NaN confidence", rule.Antecedent, rule.Consequent)
+		}
+	}
+}
+
+func TestNormalizeTransactions(t *testing.T) {
+	transactions := []Transaction{{"cola", "milk", "diaper", "bread", "milk"}, {"milk", "bread"}}
+	normalized := normalizeTransactions(transactions)
+
+	want := []Transaction{{"bread", "cola", "diaper", "milk"}, {"bread", "milk"}}
+	for i := range want {
+		if !Itemset(normalized[i]).Equal(Itemset(want[i])) {
+			t.Errorf("transaction %d normalized to %v, want %v", i, normalized[i], want[i])
+		}
+	}
+	if transactions[0][0] != "cola" {
+		t.Error("normalizing modified the input transaction")
+	}
+	// milk is counted once in the transaction that lists it twice
+	if support := calculateSupport(NewItemset("milk"), normalized); support != 1 {
+		t.Errorf("support of milk = %v, want 1", support)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	rules := GenerateAssociationRules(sampleTransactions(), 0.4, 0.6, 0, false)
+	hasInfinite := false
+	for _, rule := range rules {
+		hasInfinite = hasInfinite || math.IsInf(rule.Conviction, 1)
+	}
+	if !hasInfinite {
+		t.Fatal("no rule with infinite conviction to round-trip")
+	}
+
+	var buf bytes.Buffer
+	if err := rules.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(rules) {
+		t.Fatalf("read %d rules, want %d", len(read), len(rules))
+	}
+	for i, rule := range rules {
+		got := read[i]
+		if !got.Antecedent.Equal(rule.Antecedent) || !got.Consequent.Equal(rule.Consequent) ||
+			got.Support != rule.Support || got.Confidence != rule.Confidence || got.Lift != rule.Lift ||
+			got.Conviction != rule.Conviction || got.Leverage != rule.Leverage {
+			t.Errorf("rule %d read back as %+v, want %+v", i, got, rule)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	rules := AssociationRuleSet{{
+		Antecedent: NewItemset("beer", "bread"),
+		Consequent: NewItemset("diaper"),
+		Support:    0.4,
+		Confidence: 1,
+		Lift:       1.25,
+	}}
+	var buf bytes.Buffer
+	if err := rules.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"antecedent", "consequent", "support", "confidence", "lift"},
+		{"beer,bread", "diaper", "0.4", "1", "1.25"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
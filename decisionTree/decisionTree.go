@@ -4,151 +4,436 @@ import(
 	"fmt"
 	"math"
 	"sort"
+	"strings"
+
+	"ml/metrics"
 )
 
 // TreeNode represents a node in the decision tree
 type TreeNode struct {
-	AttributeIndex int
+	SplitColumn    int     // Column the node splits on
+	IsCategorical  bool    // Categorical splits have a child per category, numerical splits send values below Threshold left
 	Threshold      float64
 	Category       string
 	Left           *TreeNode
 	Right          *TreeNode
+	Children       map[float64]*TreeNode // Child of each category seen in training (categorical splits)
+	Fallback       *TreeNode             // Child for categories not seen in training (categorical splits)
 	Prediction     int
+	Value          float64 // Mean target of a regression leaf
+	Samples        float64         // Total weight of the training samples that reached the node
+	ClassCounts    map[int]float64 // Weight of the training samples of each class that reached the node (classification)
+	Error          float64         // Training error of the node as a leaf: misclassified weight or sum of squared errors
+	Impurity       float64         // Entropy, Gini impurity or variance of the training samples that reached the node
 }
 
 // DecisionTree represents the decision tree model
 type DecisionTree struct {
 	Root *TreeNode
+	// MaxThresholdsPerFeature limits the candidate thresholds evaluated for a numerical
+	// attribute to that many quantiles of its values; 0 evaluates every midpoint
+	MaxThresholdsPerFeature int
+	// FeatureNames optionally names the input columns in reports
+	FeatureNames []string
+	// Task is "classification" (the default when empty) or "regression"
+	Task string
+	// MaxDepth limits the depth of the tree; 0 means unlimited
+	MaxDepth int
+	// MinSamplesSplit is the minimum number of samples a node needs to be split
+	MinSamplesSplit int
+	// Criterion is the classification split criterion, "entropy" (the default when empty) or "gini"
+	Criterion string
 }
 
 // Fit builds the decision tree model
 func (dt *DecisionTree) Fit(X [][]float64, y []int, categoricalCols []bool) {
-	dt.Root = buildTree(X, y, categoricalCols)
+	dt.FitWeighted(X, y, categoricalCols, nil)
+}
+
+// FitWeighted builds the decision tree model with a weight per training sample.
+// Nil weights give every sample the same importance.
+func (dt *DecisionTree) FitWeighted(X [][]float64, y []int, categoricalCols []bool, weights []float64) {
+	dt.Task = "classification"
+	targets := make([]float64, len(y))
+	for i, label := range y {
+		targets[i] = float64(label)
+	}
+	dt.Root = dt.buildTree(X, targets, weights, categoricalCols, 0)
+}
+
+// FitRegression builds a regression tree that splits on variance reduction and predicts the mean target at its leaves
+func (dt *DecisionTree) FitRegression(X [][]float64, y []float64, categoricalCols []bool) {
+	dt.FitRegressionWeighted(X, y, categoricalCols, nil)
+}
+
+// FitRegressionWeighted builds a regression tree with a weight per training sample
+func (dt *DecisionTree) FitRegressionWeighted(X [][]float64, y []float64, categoricalCols []bool, weights []float64) {
+	dt.Task = "regression"
+	dt.Root = dt.buildTree(X, y, weights, categoricalCols, 0)
 }
 
 // Predict returns the predictions for input data
 func (dt *DecisionTree) Predict(X [][]float64) []int {
 	var predictions []int
 	for _, sample := range X {
-		predictions = append(predictions, dt.predictSample(sample))
+		predictions = append(predictions, dt.PredictOne(sample))
+	}
+	return predictions
+}
+
+// PredictOne returns the prediction for a single sample
+func (dt *DecisionTree) PredictOne(sample []float64) int {
+	return dt.predictSample(sample)
+}
+
+// PredictRegression returns the predicted targets of a regression tree for input data
+func (dt *DecisionTree) PredictRegression(X [][]float64) []float64 {
+	var predictions []float64
+	for _, sample := range X {
+		predictions = append(predictions, dt.leaf(sample).Value)
 	}
 	return predictions
 }
 
+// Evaluate returns the accuracy of the tree on the given data together with its confusion matrix,
+// indexed first by true class and then by predicted class
+func Evaluate(dt *DecisionTree, X [][]float64, y []int) (accuracy float64, confusion map[int]map[int]int) {
+	predictions := dt.Predict(X)
+	correct := 0
+	for i, prediction := range predictions {
+		if prediction == y[i] {
+			correct++
+		}
+	}
+	if len(y) > 0 {
+		accuracy = float64(correct) / float64(len(y))
+	}
+	return accuracy, ConfusionMatrix(y, predictions)
+}
+
+// ConfusionMatrix counts the samples of each true class (first index) predicted as each class (second index).
+// Every class that occurs in either yTrue or yPred has a row and a column, with zero counts where nothing was predicted.
+func ConfusionMatrix(yTrue, yPred []int) map[int]map[int]int {
+	classes := classesOf(yTrue, yPred)
+	confusion := make(map[int]map[int]int, len(classes))
+	for _, trueClass := range classes {
+		confusion[trueClass] = make(map[int]int, len(classes))
+		for _, predictedClass := range classes {
+			confusion[trueClass][predictedClass] = 0
+		}
+	}
+	for i := range yTrue {
+		confusion[yTrue[i]][yPred[i]]++
+	}
+	return confusion
+}
+
+// ClassMetrics holds the precision, recall, F1 score and number of true samples of a class
+type ClassMetrics struct {
+	Precision float64
+	Recall    float64
+	F1        float64
+	Support   int
+}
+
+// ClassificationReport holds the metrics of every class that occurs in the true or predicted labels
+type ClassificationReport struct {
+	Classes  map[int]ClassMetrics
+	Accuracy float64
+}
+
+// NewClassificationReport computes per-class precision, recall and F1 score from true and predicted labels.
+// Metrics whose denominator is zero, such as the precision of a class that is never predicted, are 0.
+func NewClassificationReport(yTrue, yPred []int) ClassificationReport {
+	confusion := ConfusionMatrix(yTrue, yPred)
+	report := ClassificationReport{Classes: make(map[int]ClassMetrics, len(confusion))}
+
+	correct := 0
+	for class := range confusion {
+		truePositives := confusion[class][class]
+		correct += truePositives
+		support, predicted := 0, 0
+		for other := range confusion {
+			support += confusion[class][other]
+			predicted += confusion[other][class]
+		}
+
+		metrics := ClassMetrics{Support: support}
+		if predicted > 0 {
+			metrics.Precision = float64(truePositives) / float64(predicted)
+		}
+		if support > 0 {
+			metrics.Recall = float64(truePositives) / float64(support)
+		}
+		if metrics.Precision+metrics.Recall > 0 {
+			metrics.F1 = 2 * metrics.Precision * metrics.Recall / (metrics.Precision + metrics.Recall)
+		}
+		report.Classes[class] = metrics
+	}
+	if len(yTrue) > 0 {
+		report.Accuracy = float64(correct) / float64(len(yTrue))
+	}
+	return report
+}
+
+// classesOf returns the sorted distinct classes of both label slices
+func classesOf(yTrue, yPred []int) []int {
+	seen := make(map[int]bool)
+	var classes []int
+	for _, labels := range [][]int{yTrue, yPred} {
+		for _, class := range labels {
+			if !seen[class] {
+				seen[class] = true
+				classes = append(classes, class)
+			}
+		}
+	}
+	sort.Ints(classes)
+	return classes
+}
+
 // predictSample returns the prediction for a single sample
 func (dt *DecisionTree) predictSample(sample []float64) int {
+	return dt.leaf(sample).Prediction
+}
+
+// leaf returns the leaf reached by a single sample
+func (dt *DecisionTree) leaf(sample []float64) *TreeNode {
 	currentNode := dt.Root
-	for currentNode.Left != nil && currentNode.Right != nil {
-		if currentNode.AttributeIndex != -1 { // Split on numerical attribute
-			if sample[currentNode.AttributeIndex] < currentNode.Threshold {
+	for !currentNode.isLeaf() {
+		if !currentNode.IsCategorical { // Split on numerical attribute
+			if sample[currentNode.SplitColumn] < currentNode.Threshold {
 				currentNode = currentNode.Left
 			} else {
 				currentNode = currentNode.Right
 			}
 		} else { // Split on categorical attribute
-			if sample[int(currentNode.Threshold)] == 0 {
-				currentNode = currentNode.Left
-			} else {
-				currentNode = currentNode.Right
+			child, ok := currentNode.Children[sample[currentNode.SplitColumn]]
+			if !ok {
+				child = currentNode.Fallback
 			}
+			currentNode = child
 		}
 	}
-	return currentNode.Prediction
+	return currentNode
+}
+
+// isLeaf reports whether the node has no children
+func (node *TreeNode) isLeaf() bool {
+	return node.Children == nil && (node.Left == nil || node.Right == nil)
+}
+
+// children returns the children that training samples reached: those of a numerical split,
+// or those of a categorical split in the order of their categories
+func (node *TreeNode) children() []*TreeNode {
+	if !node.IsCategorical {
+		return []*TreeNode{node.Left, node.Right}
+	}
+	var children []*TreeNode
+	for _, category := range node.categories() {
+		children = append(children, node.Children[category])
+	}
+	return children
+}
+
+// categories returns the sorted categories of a categorical split
+func (node *TreeNode) categories() []float64 {
+	categories := make([]float64, 0, len(node.Children))
+	for category := range node.Children {
+		categories = append(categories, category)
+	}
+	sort.Float64s(categories)
+	return categories
 }
 
 // buildTree recursively constructs the decision tree
-func buildTree(X [][]float64, y []int, categoricalCols []bool) *TreeNode {
+// Classification trees minimize the weighted entropy of the children, regression trees their weighted variance.
+func (dt *DecisionTree) buildTree(X [][]float64, y []float64, weights []float64, categoricalCols []bool, depth int) *TreeNode {
+	node := dt.newLeaf(y, weights)
+	// A single row or no attributes leaves nothing to split on
+	if len(y) <= 1 || len(X[0]) == 0 {
+		return node
+	}
+	if (dt.MaxDepth > 0 && depth >= dt.MaxDepth) || len(y) < dt.MinSamplesSplit {
+		return node
+	}
 	if len(uniqueElements(y)) == 1 {
-		return &TreeNode{Prediction: y[0]}
+		return node
 	}
 	numAttributes := len(X[0])
-	minEntropy := math.Inf(1)
+	minImpurity := math.Inf(1)
 	var bestAttributeIndex int
+	var bestIsCategorical bool
 	var bestThreshold float64
 	var bestLeftX, bestRightX [][]float64
-	var bestLeftY, bestRightY []int
+	var bestLeftY, bestRightY []float64
+	var bestLeftW, bestRightW []float64
+	var bestCategories []float64
+	var bestGroupsX [][][]float64
+	var bestGroupsY, bestGroupsW [][]float64
+	totalWeight := sumWeights(y, weights)
 
 	for i := 0; i < numAttributes; i++ {
 		if categoricalCols[i] {
-			// Split on categorical attribute
-			leftX, rightX, leftY, rightY := splitCategorical(X, y, i)
-			leftEntropy := entropy(leftY)
-			rightEntropy := entropy(rightY)
-			entropyWeighted := (float64(len(leftY))/float64(len(y)))*leftEntropy +
-				(float64(len(rightY))/float64(len(y)))*rightEntropy
-			if entropyWeighted < minEntropy {
-				minEntropy = entropyWeighted
-				bestAttributeIndex = -1 // Mark as categorical attribute split
-				bestThreshold = float64(i) // Use the attribute index as category indicator
-				bestLeftX, bestRightX = leftX, rightX
-				bestLeftY, bestRightY = leftY, rightY
+			// Split on categorical attribute, with one child per category
+			categories, groupsX, groupsY, groupsW := splitCategorical(X, y, weights, i)
+			if len(categories) < 2 {
+				continue
+			}
+			impurityWeighted := 0.0
+			for g := range groupsY {
+				impurityWeighted += (sumWeights(groupsY[g], groupsW[g]) / totalWeight) * dt.impurity(groupsY[g], groupsW[g])
+			}
+			if impurityWeighted < minImpurity {
+				minImpurity = impurityWeighted
+				bestAttributeIndex = i
+				bestIsCategorical = true
+				bestThreshold = 0
+				bestCategories = categories
+				bestGroupsX, bestGroupsY, bestGroupsW = groupsX, groupsY, groupsW
 			}
 		} else {
 			// Split on numerical attribute
 			attributeValues := getAttributeValues(X, i)
 			sort.Float64s(attributeValues)
-			for j := 0; j < len(attributeValues)-1; j++ {
-				threshold := 0.5 * (attributeValues[j] + attributeValues[j+1])
-				leftX, rightX, leftY, rightY := splitNumerical(X, y, i, threshold)
-				leftEntropy := entropy(leftY)
-				rightEntropy := entropy(rightY)
-				entropyWeighted := (float64(len(leftY))/float64(len(y)))*leftEntropy +
-					(float64(len(rightY))/float64(len(y)))*rightEntropy
-				if entropyWeighted < minEntropy {
-					minEntropy = entropyWeighted
+			for _, threshold := range dt.candidateThresholds(attributeValues) {
+				leftX, rightX, leftY, rightY, leftW, rightW := splitNumerical(X, y, weights, i, threshold)
+				if len(leftY) == 0 || len(rightY) == 0 {
+					continue
+				}
+				leftImpurity := dt.impurity(leftY, leftW)
+				rightImpurity := dt.impurity(rightY, rightW)
+				impurityWeighted := (sumWeights(leftY, leftW)/totalWeight)*leftImpurity +
+					(sumWeights(rightY, rightW)/totalWeight)*rightImpurity
+				if impurityWeighted < minImpurity {
+					minImpurity = impurityWeighted
 					bestAttributeIndex = i
+					bestIsCategorical = false
 					bestThreshold = threshold
 					bestLeftX, bestRightX = leftX, rightX
 					bestLeftY, bestRightY = leftY, rightY
+					bestLeftW, bestRightW = leftW, rightW
 				}
 			}
 		}
 	}
-	if minEntropy == math.Inf(1) {
-		return &TreeNode{Prediction: majorityVote(y)}
+	if minImpurity == math.Inf(1) {
+		return node
+	}
+	// Keep the leaf prediction and statistics on the internal node so that pruning can collapse it
+	node.SplitColumn = bestAttributeIndex
+	node.IsCategorical = bestIsCategorical
+	node.Threshold = bestThreshold
+	if bestIsCategorical {
+		node.Children = make(map[float64]*TreeNode)
+		for g, category := range bestCategories {
+			node.Children[category] = dt.buildTree(bestGroupsX[g], bestGroupsY[g], bestGroupsW[g], categoricalCols, depth+1)
+		}
+		// Unseen categories get the prediction of the node itself
+		node.Fallback = dt.newLeaf(y, weights)
+		return node
+	}
+	node.Left = dt.buildTree(bestLeftX, bestLeftY, bestLeftW, categoricalCols, depth+1)
+	node.Right = dt.buildTree(bestRightX, bestRightY, bestRightW, categoricalCols, depth+1)
+	return node
+}
+
+// newLeaf creates a leaf predicting the majority class (classification) or the mean target (regression),
+// recording the training statistics of its samples
+func (dt *DecisionTree) newLeaf(y []float64, weights []float64) *TreeNode {
+	node := &TreeNode{Samples: sumWeights(y, weights), Impurity: dt.impurity(y, weights)}
+	if dt.Task == "regression" {
+		node.Value = mean(y, weights)
+		for i, value := range y {
+			node.Error += weightOf(weights, i) * (value - node.Value) * (value - node.Value)
+		}
+		return node
+	}
+	node.Prediction = majorityVote(y, weights)
+	node.ClassCounts = make(map[int]float64)
+	for i, class := range y {
+		node.ClassCounts[int(class)] += weightOf(weights, i)
 	}
-	leftChild := buildTree(bestLeftX, bestLeftY, categoricalCols)
-	rightChild := buildTree(bestRightX, bestRightY, categoricalCols)
-	return &TreeNode{
-		AttributeIndex: bestAttributeIndex,
-		Threshold:      bestThreshold,
-		Left:           leftChild,
-		Right:          rightChild,
+	node.Error = node.Samples - node.ClassCounts[node.Prediction]
+	return node
+}
+
+// impurity returns the entropy or Gini impurity (classification) or the variance (regression) of the targets
+func (dt *DecisionTree) impurity(y []float64, weights []float64) float64 {
+	if dt.Task == "regression" {
+		return variance(y, weights)
+	}
+	if dt.Criterion == "gini" {
+		return gini(y, weights)
+	}
+	return entropy(y, weights)
+}
+
+// candidateThresholds returns the thresholds to evaluate for sorted distinct attribute values.
+// Every midpoint is returned unless MaxThresholdsPerFeature is set, in which case the midpoints
+// are sampled at evenly spaced quantiles.
+func (dt *DecisionTree) candidateThresholds(sortedValues []float64) []float64 {
+	var midpoints []float64
+	for j := 0; j < len(sortedValues)-1; j++ {
+		midpoints = append(midpoints, 0.5*(sortedValues[j]+sortedValues[j+1]))
+	}
+	if dt.MaxThresholdsPerFeature <= 0 || len(midpoints) <= dt.MaxThresholdsPerFeature {
+		return midpoints
+	}
+
+	thresholds := make([]float64, dt.MaxThresholdsPerFeature)
+	for q := range thresholds {
+		thresholds[q] = midpoints[(q+1)*len(midpoints)/(dt.MaxThresholdsPerFeature+1)]
 	}
+	return thresholds
 }
 
 // splitNumerical performs split for numerical attribute
-func splitNumerical(X [][]float64, y []int, attributeIndex int, threshold float64) ([][]float64, [][]float64, []int, []int) {
+func splitNumerical(X [][]float64, y []float64, weights []float64, attributeIndex int, threshold float64) ([][]float64, [][]float64, []float64, []float64, []float64, []float64) {
 	var leftX, rightX [][]float64
-	var leftY, rightY []int
+	var leftY, rightY []float64
+	var leftW, rightW []float64
 
 	for i, val := range X {
 		if val[attributeIndex] < threshold {
 			leftX = append(leftX, val)
 			leftY = append(leftY, y[i])
+			if weights != nil {
+				leftW = append(leftW, weights[i])
+			}
 		} else {
 			rightX = append(rightX, val)
 			rightY = append(rightY, y[i])
+			if weights != nil {
+				rightW = append(rightW, weights[i])
+			}
 		}
 	}
-	return leftX, rightX, leftY, rightY
+	return leftX, rightX, leftY, rightY, leftW, rightW
 }
 
-// splitCategorical performs split for categorical attribute
-func splitCategorical(X [][]float64, y []int, attributeIndex int) ([][]float64, [][]float64, []int, []int) {
-	var leftX, rightX [][]float64
-	var leftY, rightY []int
+// splitCategorical groups the samples by the category of a categorical attribute,
+// returning the sorted categories and the samples, targets and weights (nil without weights) of each
+func splitCategorical(X [][]float64, y []float64, weights []float64, attributeIndex int) ([]float64, [][][]float64, [][]float64, [][]float64) {
+	categories := getAttributeValues(X, attributeIndex)
+	sort.Float64s(categories)
+	group := make(map[float64]int, len(categories))
+	for g, category := range categories {
+		group[category] = g
+	}
 
+	groupsX := make([][][]float64, len(categories))
+	groupsY := make([][]float64, len(categories))
+	groupsW := make([][]float64, len(categories))
 	for i, val := range X {
-		if val[attributeIndex] == 0 {
-			leftX = append(leftX, val)
-			leftY = append(leftY, y[i])
-		} else {
-			rightX = append(rightX, val)
-			rightY = append(rightY, y[i])
+		g := group[val[attributeIndex]]
+		groupsX[g] = append(groupsX[g], val)
+		groupsY[g] = append(groupsY[g], y[i])
+		if weights != nil {
+			groupsW[g] = append(groupsW[g], weights[i])
 		}
 	}
-	return leftX, rightX, leftY, rightY
+	return categories, groupsX, groupsY, groupsW
 }
 
 // getAttributeValues returns unique values for a given attribute
@@ -173,9 +458,9 @@ func contains(slice []float64, val float64) bool {
 }
 
 // uniqueElements returns unique elements in a slice
-func uniqueElements(slice []int) []int {
-	keys := make(map[int]bool)
-	var unique []int
+func uniqueElements(slice []float64) []float64 {
+	keys := make(map[float64]bool)
+	var unique []float64
 	for _, entry := range slice {
 		if _, value := keys[entry]; !value {
 			keys[entry] = true
@@ -185,47 +470,273 @@ func uniqueElements(slice []int) []int {
 	return unique
 }
 
-// entropy calculates the entropy of a given set
-func entropy(y []int) float64 {
+// entropy calculates the weighted entropy of a given set
+func entropy(y []float64, weights []float64) float64 {
 	entropy := 0.0
-	totalSamples := len(y)
+	totalWeight := sumWeights(y, weights)
+	if totalWeight == 0 {
+		return 0
+	}
 	uniqueClasses := uniqueElements(y)
 	for _, class := range uniqueClasses {
-		proportion := float64(count(y, class)) / float64(totalSamples)
+		proportion := count(y, weights, class) / totalWeight
+		if proportion == 0 {
+			continue
+		}
 		entropy -= proportion * math.Log2(proportion)
 	}
 	return entropy
 }
 
-// count counts occurrences of an element in a slice
-func count(slice []int, val int) int {
-	count := 0
-	for _, item := range slice {
+// gini calculates the weighted Gini impurity of a given set
+func gini(y []float64, weights []float64) float64 {
+	totalWeight := sumWeights(y, weights)
+	if totalWeight == 0 {
+		return 0
+	}
+	impurity := 1.0
+	for _, class := range uniqueElements(y) {
+		proportion := count(y, weights, class) / totalWeight
+		impurity -= proportion * proportion
+	}
+	return impurity
+}
+
+// variance calculates the weighted variance of a given set
+func variance(y []float64, weights []float64) float64 {
+	if sumWeights(y, weights) == 0 {
+		return 0
+	}
+	average := mean(y, weights)
+	sumSquares := 0.0
+	for i, value := range y {
+		sumSquares += weightOf(weights, i) * (value - average) * (value - average)
+	}
+	return sumSquares / sumWeights(y, weights)
+}
+
+// mean calculates the weighted mean of a given set
+func mean(y []float64, weights []float64) float64 {
+	totalWeight := sumWeights(y, weights)
+	if totalWeight == 0 {
+		return 0
+	}
+	sum := 0.0
+	for i, value := range y {
+		sum += weightOf(weights, i) * value
+	}
+	return sum / totalWeight
+}
+
+// count sums the weights of the occurrences of an element in a slice
+func count(slice []float64, weights []float64, val float64) float64 {
+	count := 0.0
+	for i, item := range slice {
 		if item == val {
-			count++
+			count += weightOf(weights, i)
 		}
 	}
 	return count
 }
 
-// majorityVote returns the class with the majority vote
-func majorityVote(y []int) int {
-	classCounts := make(map[int]int)
-	for _, class := range y {
-		classCounts[class]++
+// sumWeights returns the total weight of the samples in a slice
+func sumWeights(y []float64, weights []float64) float64 {
+	total := 0.0
+	for i := range y {
+		total += weightOf(weights, i)
+	}
+	return total
+}
+
+// weightOf returns the weight of the i-th sample, treating nil weights as uniform
+func weightOf(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+// majorityVote returns the class with the largest total weight, the smaller class on ties
+func majorityVote(y []float64, weights []float64) int {
+	classCounts := make(map[float64]float64)
+	for i, class := range y {
+		classCounts[class] += weightOf(weights, i)
 	}
-	maxCount := 0
-	majorityClass := 0
+	maxCount := 0.0
+	majorityClass := 0.0
 	for class, count := range classCounts {
-		if count > maxCount {
+		// Break ties by the smaller class so that the result does not depend on map iteration order
+		if count > maxCount || (count == maxCount && class < majorityClass) {
 			maxCount = count
 			majorityClass = class
 		}
 	}
-	return majorityClass
+	return int(majorityClass)
 }
 
-// Pruning functions...
+// FeatureImportances returns the impurity decrease of the splits on each of the numFeatures columns,
+// weighted by the training weight reaching each split and normalized to sum to 1.
+// All importances are zero if the tree has not been fitted or has no splits.
+func (dt *DecisionTree) FeatureImportances(numFeatures int) []float64 {
+	importances := make([]float64, numFeatures)
+	addImpurityDecrease(dt.Root, importances)
+
+	total := 0.0
+	for _, importance := range importances {
+		total += importance
+	}
+	if total > 0 {
+		for i := range importances {
+			importances[i] /= total
+		}
+	}
+	return importances
+}
+
+// FeatureImportancesNamed returns FeatureImportances keyed by dt.FeatureNames, falling back to feature[i]
+func (dt *DecisionTree) FeatureImportancesNamed(numFeatures int) map[string]float64 {
+	return metrics.NamedValues(dt.FeatureNames, dt.FeatureImportances(numFeatures))
+}
+
+// addImpurityDecrease adds the weighted impurity decrease of every split below node to importances
+func addImpurityDecrease(node *TreeNode, importances []float64) {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	importances[node.SplitColumn] += node.Samples * node.Impurity
+	for _, child := range node.children() {
+		importances[node.SplitColumn] -= child.Samples * child.Impurity
+		addImpurityDecrease(child, importances)
+	}
+}
+
+// ToDOT returns the tree as a Graphviz digraph, e.g. to render with dot -Tpng.
+// Numerical splits show their condition, with the left branch taken when it is true, categorical splits
+// label each branch with its category, and leaves show their prediction and sample count.
+// Features are named by featureNames, or dt.FeatureNames if nil, or feature[i].
+func (dt *DecisionTree) ToDOT(featureNames []string) string {
+	if featureNames == nil {
+		featureNames = dt.FeatureNames
+	}
+	var b strings.Builder
+	b.WriteString("digraph DecisionTree {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	if dt.Root != nil {
+		nextID := 0
+		dt.writeDOTNode(&b, dt.Root, featureNames, &nextID)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeDOTNode writes node and its subtree, numbering nodes in preorder, and returns the id of node
+func (dt *DecisionTree) writeDOTNode(b *strings.Builder, node *TreeNode, featureNames []string, nextID *int) int {
+	id := *nextID
+	*nextID++
+
+	if node.isLeaf() {
+		prediction := fmt.Sprintf("class = %d", node.Prediction)
+		if dt.Task == "regression" {
+			prediction = fmt.Sprintf("value = %g", node.Value)
+		}
+		fmt.Fprintf(b, "\t%d [label=\"%s\\nsamples = %g\"];\n", id, prediction, node.Samples)
+		return id
+	}
+
+	name := featureName(featureNames, node.SplitColumn)
+	if node.IsCategorical {
+		// Categorical splits show the column and label each edge with its category
+		fmt.Fprintf(b, "\t%d [label=\"%s\"];\n", id, strings.ReplaceAll(name, "\"", "\\\""))
+		for _, category := range node.categories() {
+			childID := dt.writeDOTNode(b, node.Children[category], featureNames, nextID)
+			fmt.Fprintf(b, "\t%d -> %d [label=\"%g\"];\n", id, childID, category)
+		}
+		fallbackID := dt.writeDOTNode(b, node.Fallback, featureNames, nextID)
+		fmt.Fprintf(b, "\t%d -> %d [label=\"other\"];\n", id, fallbackID)
+		return id
+	}
+	condition := fmt.Sprintf("%s < %g", name, node.Threshold)
+	fmt.Fprintf(b, "\t%d [label=\"%s\"];\n", id, strings.ReplaceAll(condition, "\"", "\\\""))
+
+	leftID := dt.writeDOTNode(b, node.Left, featureNames, nextID)
+	fmt.Fprintf(b, "\t%d -> %d [label=\"true\"];\n", id, leftID)
+	rightID := dt.writeDOTNode(b, node.Right, featureNames, nextID)
+	fmt.Fprintf(b, "\t%d -> %d [label=\"false\"];\n", id, rightID)
+	return id
+}
+
+// featureName returns the name of a column, falling back to feature[i] when it is not named
+func featureName(featureNames []string, i int) string {
+	if i < len(featureNames) {
+		return featureNames[i]
+	}
+	return fmt.Sprintf("feature[%d]", i)
+}
+
+// DecisionPath returns the conditions the sample satisfies from the root to its leaf, such as "feature[2] < 1.35",
+// followed by the prediction of the leaf. Features are named by dt.FeatureNames or feature[i].
+func (dt *DecisionTree) DecisionPath(sample []float64) []string {
+	var path []string
+	currentNode := dt.Root
+	for !currentNode.isLeaf() {
+		name := featureName(dt.FeatureNames, currentNode.SplitColumn)
+		value := sample[currentNode.SplitColumn]
+		if currentNode.IsCategorical {
+			child, ok := currentNode.Children[value]
+			if ok {
+				path = append(path, fmt.Sprintf("%s == %g", name, value))
+			} else {
+				child = currentNode.Fallback
+				path = append(path, fmt.Sprintf("%s == %g (unseen category)", name, value))
+			}
+			currentNode = child
+		} else if value < currentNode.Threshold {
+			path = append(path, fmt.Sprintf("%s < %g", name, currentNode.Threshold))
+			currentNode = currentNode.Left
+		} else {
+			path = append(path, fmt.Sprintf("%s >= %g", name, currentNode.Threshold))
+			currentNode = currentNode.Right
+		}
+	}
+	if dt.Task == "regression" {
+		return append(path, fmt.Sprintf("value = %g", currentNode.Value))
+	}
+	return append(path, fmt.Sprintf("class = %d", currentNode.Prediction))
+}
+
+// Prune performs cost-complexity pruning: working bottom-up, it collapses every subtree whose training error,
+// as a fraction of the training weight, plus alpha per leaf is not lower than that of the subtree's root as a single leaf.
+// Larger alpha values give smaller trees.
+func (dt *DecisionTree) Prune(alpha float64) {
+	if dt.Root == nil || dt.Root.Samples == 0 {
+		return
+	}
+	prune(dt.Root, alpha*dt.Root.Samples)
+}
+
+// prune prunes the subtree below node with the leaf penalty expressed in training weight,
+// returning the training error and number of leaves of what remains
+func prune(node *TreeNode, penalty float64) (float64, int) {
+	if node.isLeaf() {
+		return node.Error, 1
+	}
+	subtreeError := 0.0
+	leaves := 0
+	for _, child := range node.children() {
+		childError, childLeaves := prune(child, penalty)
+		subtreeError += childError
+		leaves += childLeaves
+	}
+
+	if node.Error+penalty <= subtreeError+penalty*float64(leaves) {
+		node.Left = nil
+		node.Right = nil
+		node.Children = nil
+		node.Fallback = nil
+		return node.Error, 1
+	}
+	return subtreeError, leaves
+}
 
 func main() {
 	X := [][]float64{
@@ -250,4 +761,24 @@ func main() {
 	}
 	predictions := dt.Predict(newSamples)
 	fmt.Println("Predictions:", predictions)
+	fmt.Println("Decision path:", dt.DecisionPath(newSamples[0]))
+
+	// Collapse splits that do not pay for their extra leaf
+	dt.Prune(0.01)
+	fmt.Print(dt.ToDOT([]string{"sepal length", "sepal width", "petal length", "petal width"}))
+
+	// Evaluate the tree on the training data
+	accuracy, confusion := Evaluate(&dt, X, y)
+	fmt.Println("Accuracy:", accuracy)
+	fmt.Println("Confusion matrix:", confusion)
+	report := NewClassificationReport(y, dt.Predict(X))
+	for class, metrics := range report.Classes {
+		fmt.Printf("Class %d: precision %.2f, recall %.2f, F1 %.2f, support %d\n", class, metrics.Precision, metrics.Recall, metrics.F1, metrics.Support)
+	}
+
+	// Fit a regression tree on a continuous target
+	targets := []float64{2.4, 1.8, 2.2, 1.9}
+	regressionTree := DecisionTree{}
+	regressionTree.FitRegression(X, targets, categoricalCols)
+	fmt.Println("Regression predictions:", regressionTree.PredictRegression(newSamples))
 }
@@ -0,0 +1,294 @@
+package decisionTree
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// noisyData returns n samples of two numerical columns labelled by x0 + x1 > 1,
+// with the given fraction of labels flipped
+func noisyData(rng *rand.Rand, n int, noise float64) ([][]float64, []int) {
+	X := make([][]float64, n)
+	y := make([]int, n)
+	for i := range X {
+		X[i] = []float64{rng.Float64(), rng.Float64()}
+		if X[i][0]+X[i][1] > 1 {
+			y[i] = 1
+		}
+		if rng.Float64() < noise {
+			y[i] = 1 - y[i]
+		}
+	}
+	return X, y
+}
+
+// countNodes returns the number of nodes below and including node
+func countNodes(node *TreeNode) int {
+	if node.isLeaf() {
+		return 1
+	}
+	count := 1
+	for _, child := range node.children() {
+		count += countNodes(child)
+	}
+	return count
+}
+
+// height returns the number of edges on the longest path from node to a leaf
+func height(node *TreeNode) int {
+	if node.isLeaf() {
+		return 0
+	}
+	longest := 0
+	for _, child := range node.children() {
+		if h := height(child) + 1; h > longest {
+			longest = h
+		}
+	}
+	return longest
+}
+
+func TestMaxThresholdsPerFeature(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	X, y := noisyData(rng, 400, 0)
+	XTest, yTest := noisyData(rng, 400, 0)
+
+	exact := &DecisionTree{}
+	exact.Fit(X, y, []bool{false, false})
+	sampled := &DecisionTree{MaxThresholdsPerFeature: 16}
+	sampled.Fit(X, y, []bool{false, false})
+
+	exactAccuracy, _ := Evaluate(exact, XTest, yTest)
+	sampledAccuracy, _ := Evaluate(sampled, XTest, yTest)
+	if sampledAccuracy < exactAccuracy-0.05 {
+		t.Errorf("sampled thresholds accuracy %v, exact %v", sampledAccuracy, exactAccuracy)
+	}
+}
+
+func BenchmarkFit(b *testing.B) {
+	X, y := noisyData(rand.New(rand.NewSource(1)), 2000, 0.1)
+	for _, maxThresholds := range []int{0, 32} {
+		name := "exact"
+		if maxThresholds > 0 {
+			name = "quantiles"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dt := &DecisionTree{MaxThresholdsPerFeature: maxThresholds, MaxDepth: 6}
+				dt.Fit(X, y, []bool{false, false})
+			}
+		})
+	}
+}
+
+func TestFitTinyDatasets(t *testing.T) {
+	// A single row becomes a leaf predicting its class
+	dt := &DecisionTree{}
+	dt.Fit([][]float64{{1, 2}}, []int{3}, []bool{false, false})
+	if !dt.Root.isLeaf() || dt.PredictOne([]float64{5, 5}) != 3 {
+		t.Errorf("single-row tree predicts %d, want a leaf predicting 3", dt.PredictOne([]float64{5, 5}))
+	}
+
+	// A single class needs no split
+	dt.Fit([][]float64{{1}, {2}, {3}}, []int{1, 1, 1}, []bool{false})
+	if !dt.Root.isLeaf() || dt.PredictOne([]float64{2}) != 1 {
+		t.Error("single-class tree should be a leaf predicting 1")
+	}
+}
+
+func TestFitDegenerateSplits(t *testing.T) {
+	// Identical features leave every split with an empty side
+	dt := &DecisionTree{}
+	dt.Fit([][]float64{{1, 1}, {1, 1}, {1, 1}}, []int{0, 1, 1}, []bool{false, false})
+	if !dt.Root.isLeaf() || dt.PredictOne([]float64{1, 1}) != 1 {
+		t.Error("tree on identical features should be a leaf predicting the majority class 1")
+	}
+
+	// Rows without attributes
+	dt.Fit([][]float64{{}, {}}, []int{0, 0}, []bool{})
+	if !dt.Root.isLeaf() {
+		t.Error("tree without attributes should be a leaf")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	X, y := noisyData(rand.New(rand.NewSource(2)), 300, 0.2)
+	unlimited := &DecisionTree{}
+	unlimited.Fit(X, y, []bool{false, false})
+	for _, maxDepth := range []int{1, 2, 4} {
+		dt := &DecisionTree{MaxDepth: maxDepth}
+		dt.Fit(X, y, []bool{false, false})
+		if h := height(dt.Root); h > maxDepth {
+			t.Errorf("MaxDepth %d gave a tree of height %d", maxDepth, h)
+		}
+	}
+	if height(unlimited.Root) <= 4 {
+		t.Errorf("unlimited tree has height %d, expected deeper on noisy data", height(unlimited.Root))
+	}
+}
+
+func TestMixedCategoricalAndNumericalSplits(t *testing.T) {
+	// Column 0 is a category code, column 1 numerical; the class is 1 for category 2 with a value above 5
+	var X [][]float64
+	var y []int
+	for category := 0.0; category < 3; category++ {
+		for value := 0.0; value < 10; value++ {
+			X = append(X, []float64{category, value})
+			label := 0
+			if category == 2 && value > 5 {
+				label = 1
+			}
+			y = append(y, label)
+		}
+	}
+	dt := &DecisionTree{}
+	dt.Fit(X, y, []bool{true, false})
+
+	for i, sample := range X {
+		if prediction := dt.PredictOne(sample); prediction != y[i] {
+			t.Errorf("sample %v predicted %d, want %d", sample, prediction, y[i])
+		}
+	}
+	if !dt.Root.IsCategorical || dt.Root.SplitColumn != 0 {
+		t.Errorf("root splits on column %d (categorical %v), want categorical column 0", dt.Root.SplitColumn, dt.Root.IsCategorical)
+	}
+	if child := dt.Root.Children[2]; child.IsCategorical || child.SplitColumn != 1 {
+		t.Errorf("category 2 splits on column %d (categorical %v), want numerical column 1", child.SplitColumn, child.IsCategorical)
+	}
+}
+
+func TestToDOTGolden(t *testing.T) {
+	dt := &DecisionTree{}
+	// Color 1 is always class 1, and color 0 is class 1 only for a large size
+	X := [][]float64{{1, 0}, {2, 0}, {3, 0}, {4, 0}, {1, 1}, {4, 1}}
+	dt.Fit(X, []int{0, 0, 1, 1, 1, 1}, []bool{false, true})
+
+	want, err := os.ReadFile("testdata/tree.dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dt.ToDOT([]string{"size", "color"}); got != string(want) {
+		t.Errorf("ToDOT =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCriteriaAgreeOnSeparableData(t *testing.T) {
+	X := [][]float64{{0, 1}, {1, 2}, {2, 0}, {3, 3}, {6, 1}, {7, 2}, {8, 0}, {9, 3}}
+	y := []int{0, 0, 0, 0, 1, 1, 1, 1}
+	entropyTree := &DecisionTree{Criterion: "entropy"}
+	entropyTree.Fit(X, y, []bool{false, false})
+	giniTree := &DecisionTree{Criterion: "gini"}
+	giniTree.Fit(X, y, []bool{false, false})
+
+	queries := append(X, []float64{-1, 5}, []float64{4.4, 0}, []float64{5.6, 9})
+	for _, query := range queries {
+		if e, g := entropyTree.PredictOne(query), giniTree.PredictOne(query); e != g {
+			t.Errorf("query %v: entropy predicts %d, gini %d", query, e, g)
+		}
+	}
+	if accuracy, _ := Evaluate(giniTree, X, y); accuracy != 1 {
+		t.Errorf("gini tree training accuracy %v, want 1", accuracy)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	X, y := noisyData(rng, 300, 0.15)
+	XTest, yTest := noisyData(rng, 300, 0.15)
+
+	dt := &DecisionTree{}
+	dt.Fit(X, y, []bool{false, false})
+	before := countNodes(dt.Root)
+	accuracyBefore, _ := Evaluate(dt, XTest, yTest)
+
+	dt.Prune(0.01)
+	after := countNodes(dt.Root)
+	accuracyAfter, _ := Evaluate(dt, XTest, yTest)
+	if after >= before {
+		t.Errorf("pruning kept %d of %d nodes", after, before)
+	}
+	if accuracyAfter < accuracyBefore {
+		t.Errorf("holdout accuracy dropped from %v to %v", accuracyBefore, accuracyAfter)
+	}
+}
+
+func TestDecisionPathLengthIsLeafDepth(t *testing.T) {
+	X, y := noisyData(rand.New(rand.NewSource(4)), 100, 0.1)
+	dt := &DecisionTree{}
+	dt.Fit(X, y, []bool{false, false})
+
+	for _, sample := range X[:20] {
+		depth := 0
+		for node := dt.Root; !node.isLeaf(); depth++ {
+			if sample[node.SplitColumn] < node.Threshold {
+				node = node.Left
+			} else {
+				node = node.Right
+			}
+		}
+		// One condition per edge, then the prediction
+		if path := dt.DecisionPath(sample); len(path) != depth+1 {
+			t.Errorf("path %v has %d entries for a leaf at depth %d", path, len(path), depth)
+		}
+	}
+}
+
+func TestFeatureImportancesNamed(t *testing.T) {
+	// The label depends only on the second column
+	X := [][]float64{{1, 0}, {2, 0}, {3, 0}, {1, 1}, {2, 1}, {3, 1}, {1, 0}, {3, 1}}
+	y := []int{0, 0, 0, 1, 1, 1, 0, 1}
+	dt := &DecisionTree{FeatureNames: []string{"noise", "signal"}}
+	dt.Fit(X, y, []bool{false, false})
+
+	named := dt.FeatureImportancesNamed(2)
+	if named["signal"] != 1 || named["noise"] != 0 {
+		t.Errorf("FeatureImportancesNamed = %v, want all importance on signal", named)
+	}
+}
+
+func TestMajorityVoteTieGoesToSmallerClass(t *testing.T) {
+	y := []float64{3, 1, 2, 2, 1, 3}
+	weights := []float64{1, 0.5, 0.25, 0.75, 0.5, 0}
+	for run := 0; run < 20; run++ {
+		if class := majorityVote(y, weights); class != 1 {
+			t.Fatalf("majorityVote = %d, want the smallest tied class 1", class)
+		}
+	}
+}
+
+func TestConfusionMatrixIncludesClassesFromEitherSide(t *testing.T) {
+	// Class 2 occurs only in the truth, class 3 only in the predictions
+	yTrue := []int{0, 1, 1, 2}
+	yPred := []int{0, 1, 3, 1}
+
+	confusion := ConfusionMatrix(yTrue, yPred)
+	for _, class := range []int{0, 1, 2, 3} {
+		row, ok := confusion[class]
+		if !ok || len(row) != 4 {
+			t.Fatalf("class %d has row %v, want counts for all 4 classes", class, row)
+		}
+	}
+	if confusion[2][1] != 1 || confusion[2][2] != 0 || confusion[1][3] != 1 {
+		t.Errorf("confusion matrix %v", confusion)
+	}
+	for predicted, count := range confusion[3] {
+		if count != 0 {
+			t.Errorf("class 3 never occurs in the truth but has %d samples predicted as %d", count, predicted)
+		}
+	}
+
+	report := NewClassificationReport(yTrue, yPred)
+	if len(report.Classes) != 4 {
+		t.Fatalf("report has %d classes, want 4", len(report.Classes))
+	}
+	if onlyTrue := report.Classes[2]; onlyTrue.Support != 1 || onlyTrue.Precision != 0 || onlyTrue.Recall != 0 || onlyTrue.F1 != 0 {
+		t.Errorf("class only in the truth: %+v, want support 1 and zero metrics", onlyTrue)
+	}
+	if onlyPredicted := report.Classes[3]; onlyPredicted.Support != 0 || onlyPredicted.Precision != 0 || onlyPredicted.Recall != 0 || onlyPredicted.F1 != 0 {
+		t.Errorf("class only in the predictions: %+v, want zero support and metrics", onlyPredicted)
+	}
+	if report.Classes[1].Precision != 0.5 || report.Classes[1].Recall != 0.5 || report.Accuracy != 0.5 {
+		t.Errorf("class 1 %+v and accuracy %v, want precision, recall and accuracy 0.5", report.Classes[1], report.Accuracy)
+	}
+}
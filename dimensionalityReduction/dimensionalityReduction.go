@@ -10,34 +10,61 @@ import (
 type PCA struct {
 	Components         int       // Number of principal components
 	Mean               []float64 // Mean of each feature
-	Vectors            [][]float64 // Principal components
+	Vectors            [][]float64 // Principal components, one row of feature weights per component
 	ExplainedVariance  []float64 // Explained variance
 	ExplainedVarianceRatio  []float64 // Explained variance ratio
+	CumulativeExplainedVarianceRatio []float64 // Running total of the explained variance ratio
+	MaxIter            int       // Maximum Jacobi rotations in the eigen solver; 0 uses 1000
+	Tol                float64   // Largest off-diagonal value accepted as converged; 0 uses 1e-10
+	Converged          bool      // Whether the last fit reached Tol before running out of iterations
+	Iterations         int       // Jacobi rotations (Fit) or sweeps over all column pairs (FitSVD) used by the last fit
 }
 
+const (
+	defaultMaxIter = 1000
+	defaultTol     = 1e-10
+	maxSweeps      = 100 // Maximum passes over all column pairs in the SVD
+)
+
 // Fit method computes the mean and principal components of the input data
 func (p *PCA) Fit(data [][]float64) {
+	p.fit(data)
+}
+
+// FitTransform fits the PCA to the input data and projects it onto the principal components,
+// reusing the centered data computed while fitting
+func (p *PCA) FitTransform(data [][]float64) [][]float64 {
+	return p.project(p.fit(data))
+}
+
+// FitSVD computes the mean and principal components of the input data from the singular value decomposition
+// of the centered data, without forming the covariance matrix. Forming the covariance squares the condition number
+// of the data, so prefer FitSVD over Fit for nearly collinear or high-dimensional features.
+func (p *PCA) FitSVD(data [][]float64) {
 	rows := len(data)
-	cols := len(data[0])
+	centered := p.center(data)
 
-	// Compute mean of each feature
-	p.Mean = make([]float64, cols)
-	for i := 0; i < cols; i++ {
-		sum := 0.0
-		for j := 0; j < rows; j++ {
-			sum += data[j][i]
-		}
-		p.Mean[i] = sum / float64(rows)
+	tol := p.Tol
+	if tol <= 0 {
+		tol = defaultTol
 	}
-
-	// Subtract mean from data
-	centered := make([][]float64, rows)
-	for i := 0; i < rows; i++ {
-		centered[i] = make([]float64, cols)
-		for j := 0; j < cols; j++ {
-			centered[i][j] = data[i][j] - p.Mean[j]
-		}
+	singularValues, vectors, iterations, converged := svd(centered, maxSweeps, tol)
+	p.Iterations = iterations
+	p.Converged = converged
+
+	// The covariance eigenvalues are the squared singular values scaled like the covariance
+	values := make([]float64, len(singularValues))
+	for i, singularValue := range singularValues {
+		values[i] = singularValue * singularValue / float64(rows-1)
 	}
+	p.setComponents(values, vectors)
+}
+
+// fit computes the mean and principal components of the input data and returns the centered data
+func (p *PCA) fit(data [][]float64) [][]float64 {
+	rows := len(data)
+	cols := len(data[0])
+	centered := p.center(data)
 
 	// Compute covariance matrix
 	var covariance [][]float64
@@ -56,31 +83,72 @@ func (p *PCA) Fit(data [][]float64) {
 	}
 
 	// Compute eigenvectors and eigenvalues of covariance matrix
-	values, vectors := eigen(covariance)
+	maxIter := p.MaxIter
+	if maxIter <= 0 {
+		maxIter = defaultMaxIter
+	}
+	tol := p.Tol
+	if tol <= 0 {
+		tol = defaultTol
+	}
+	values, vectors, iterations, converged := eigen(covariance, maxIter, tol)
+	p.Iterations = iterations
+	p.Converged = converged
+	p.setComponents(values, vectors)
+	return centered
+}
 
-	// Sort eigenvectors by eigenvalues
-	sortEigen := func(eigenvalues []float64, eigenvectors [][]float64) {
-		sortedIndices := make([]int, len(eigenvalues))
-		for i := range sortedIndices {
-			sortedIndices[i] = i
+// center computes the mean of each feature and returns the data with the means subtracted
+func (p *PCA) center(data [][]float64) [][]float64 {
+	rows := len(data)
+	cols := len(data[0])
+
+	// Compute mean of each feature
+	p.Mean = make([]float64, cols)
+	for i := 0; i < cols; i++ {
+		sum := 0.0
+		for j := 0; j < rows; j++ {
+			sum += data[j][i]
 		}
-		sortByEigen := func(i, j int) bool { return eigenvalues[i] > eigenvalues[j] }
-		sort.Slice(sortedIndices, sortByEigen)
+		p.Mean[i] = sum / float64(rows)
+	}
 
-		for i := 0; i < len(eigenvectors); i++ {
-			tempCol := make([]float64, len(eigenvectors[0]))
-			for j := range sortedIndices {
-				tempCol[j] = eigenvectors[sortedIndices[j]][i]
-			}
-			for j := range tempCol {
-				eigenvectors[j][i] = tempCol[j]
-			}
+	// Subtract mean from data
+	centered := make([][]float64, rows)
+	for i := 0; i < rows; i++ {
+		centered[i] = make([]float64, cols)
+		for j := 0; j < cols; j++ {
+			centered[i][j] = data[i][j] - p.Mean[j]
 		}
 	}
-	sortEigen(values, vectors)
+	return centered
+}
 
-	// Select only the top Components eigenvectors
-	p.Vectors = vectors[:p.Components]
+// setComponents keeps the eigenvectors (the columns of vectors) of the Components largest eigenvalues
+// of the covariance matrix and computes the variance they explain
+func (p *PCA) setComponents(values []float64, vectors [][]float64) {
+	cols := len(vectors)
+
+	// Sort eigenvalues in decreasing order
+	sortedIndices := make([]int, len(values))
+	for i := range sortedIndices {
+		sortedIndices[i] = i
+	}
+	sort.Slice(sortedIndices, func(i, j int) bool { return values[sortedIndices[i]] > values[sortedIndices[j]] })
+	sortedValues := make([]float64, len(values))
+	for i, index := range sortedIndices {
+		sortedValues[i] = values[index]
+	}
+	values = sortedValues
+
+	// Select the top Components eigenvectors, which are the columns of vectors
+	p.Vectors = make([][]float64, p.Components)
+	for c := range p.Vectors {
+		p.Vectors[c] = make([]float64, cols)
+		for f := 0; f < cols; f++ {
+			p.Vectors[c][f] = vectors[f][sortedIndices[c]]
+		}
+	}
 
 	// Compute explained variance
 	p.ExplainedVariance = make([]float64, p.Components)
@@ -97,6 +165,14 @@ func (p *PCA) Fit(data [][]float64) {
 	for i := 0; i < p.Components; i++ {
 		p.ExplainedVarianceRatio[i] = p.ExplainedVariance[i] / totalVariance
 	}
+
+	// Compute cumulative explained variance ratio
+	p.CumulativeExplainedVarianceRatio = make([]float64, p.Components)
+	cumulative := 0.0
+	for i, ratio := range p.ExplainedVarianceRatio {
+		cumulative += ratio
+		p.CumulativeExplainedVarianceRatio[i] = cumulative
+	}
 }
 
 // Transform method projects the input data onto the principal components
@@ -113,6 +189,14 @@ func (p *PCA) Transform(data [][]float64) [][]float64 {
 		}
 	}
 
+	return p.project(centered)
+}
+
+// project projects centered data onto the principal components
+func (p *PCA) project(centered [][]float64) [][]float64 {
+	rows := len(centered)
+	cols := len(centered[0])
+
 	// Project data onto principal components
 	var transformed [][]float64
 	transformed = make([][]float64, rows)
@@ -121,7 +205,7 @@ func (p *PCA) Transform(data [][]float64) [][]float64 {
 		for j := 0; j < p.Components; j++ {
 			sum := 0.0
 			for k := 0; k < cols; k++ {
-				sum += centered[i][k] * p.Vectors[k][j]
+				sum += centered[i][k] * p.Vectors[j][k]
 			}
 			transformed[i][j] = sum
 		}
@@ -129,14 +213,265 @@ func (p *PCA) Transform(data [][]float64) [][]float64 {
 	return transformed
 }
 
-// eigen computes the eigenvalues and eigenvectors of a symmetric matrix
-func eigen(matrix [][]float64) (values []float64, vectors [][]float64) {
+// InverseTransform maps projected data back to the original feature space. Only the variance along the
+// kept components is recovered, so the result equals the input of Transform when Components is the number of features.
+func (p *PCA) InverseTransform(transformed [][]float64) [][]float64 {
+	cols := len(p.Mean)
+	reconstructed := make([][]float64, len(transformed))
+	for i, row := range transformed {
+		reconstructed[i] = make([]float64, cols)
+		for k := 0; k < cols; k++ {
+			sum := p.Mean[k]
+			for j, value := range row {
+				sum += value * p.Vectors[j][k]
+			}
+			reconstructed[i][k] = sum
+		}
+	}
+	return reconstructed
+}
+
+// ReconstructionError returns the mean squared error between the data and its reconstruction from the
+// principal components, which measures the information lost by keeping only Components components
+func (p *PCA) ReconstructionError(data [][]float64) float64 {
+	reconstructed := p.InverseTransform(p.Transform(data))
+	sum := 0.0
+	count := 0
+	for i, row := range data {
+		for j, value := range row {
+			diff := value - reconstructed[i][j]
+			sum += diff * diff
+			count++
+		}
+	}
+	return sum / float64(count)
+}
+
+// IncrementalPCA fits a PCA from mini-batches, keeping only the running mean and scatter matrix of the data seen so far
+type IncrementalPCA struct {
+	PCA
+	NumSamples int         // Number of samples seen so far
+	scatter    [][]float64 // Sum of outer products of the deviations from the running mean
+}
+
+// NewIncrementalPCA creates a new incremental PCA keeping the given number of components
+func NewIncrementalPCA(components int) *IncrementalPCA {
+	return &IncrementalPCA{PCA: PCA{Components: components}}
+}
+
+// PartialFit merges a batch into the running mean and scatter matrix with the pairwise update of Chan et al.,
+// which gives the same statistics as a single pass over all the data. Call Finalize to recompute the components.
+func (ip *IncrementalPCA) PartialFit(batch [][]float64) {
+	if len(batch) == 0 {
+		return
+	}
+	rows := len(batch)
+	cols := len(batch[0])
+	if ip.NumSamples == 0 {
+		ip.Mean = make([]float64, cols)
+		ip.scatter = make([][]float64, cols)
+		for i := range ip.scatter {
+			ip.scatter[i] = make([]float64, cols)
+		}
+	}
+
+	// Mean and scatter matrix of the batch alone
+	batchPCA := &PCA{}
+	centered := batchPCA.center(batch)
+
+	// Shift between the running mean and the batch mean
+	total := ip.NumSamples + rows
+	delta := make([]float64, cols)
+	for j := 0; j < cols; j++ {
+		delta[j] = batchPCA.Mean[j] - ip.Mean[j]
+	}
+	correction := float64(ip.NumSamples) * float64(rows) / float64(total)
+
+	for i := 0; i < cols; i++ {
+		for j := 0; j < cols; j++ {
+			sum := 0.0
+			for k := 0; k < rows; k++ {
+				sum += centered[k][i] * centered[k][j]
+			}
+			ip.scatter[i][j] += sum + delta[i]*delta[j]*correction
+		}
+	}
+	for j := 0; j < cols; j++ {
+		ip.Mean[j] += delta[j] * float64(rows) / float64(total)
+	}
+	ip.NumSamples = total
+}
+
+// Finalize computes the principal components from the covariance of all the samples seen so far.
+// The covariance needs at least two samples, so it returns an error if fewer have been seen.
+func (ip *IncrementalPCA) Finalize() error {
+	if ip.NumSamples < 2 {
+		return fmt.Errorf("need at least 2 samples to compute the covariance, got %d", ip.NumSamples)
+	}
+	cols := len(ip.scatter)
+	covariance := make([][]float64, cols)
+	for i := range covariance {
+		covariance[i] = make([]float64, cols)
+		for j := range covariance[i] {
+			covariance[i][j] = ip.scatter[i][j] / float64(ip.NumSamples-1)
+		}
+	}
+
+	maxIter := ip.MaxIter
+	if maxIter <= 0 {
+		maxIter = defaultMaxIter
+	}
+	tol := ip.Tol
+	if tol <= 0 {
+		tol = defaultTol
+	}
+	values, vectors, iterations, converged := eigen(covariance, maxIter, tol)
+	ip.Iterations = iterations
+	ip.Converged = converged
+	ip.setComponents(values, vectors)
+	return nil
+}
+
+// LDA struct holds the Linear Discriminant Analysis parameters
+type LDA struct {
+	Components             int         // Number of discriminant components, at most the number of classes minus one
+	Mean                   []float64   // Mean of each feature
+	Vectors                [][]float64 // Discriminant directions, one row of feature weights per component
+	ExplainedVarianceRatio []float64   // Share of the between-class separation along each component
+}
+
+// Fit computes the directions that maximize the between-class scatter relative to the within-class scatter.
+// The generalized eigenproblem is solved by whitening the within-class scatter and diagonalizing the whitened
+// between-class scatter, so the projected classes have unit within-class variance along every component.
+func (l *LDA) Fit(X [][]float64, y []int, components int) {
+	rows := len(X)
+	cols := len(X[0])
+
+	// Overall and per-class means
+	l.Mean = make([]float64, cols)
+	classMeans := make(map[int][]float64)
+	classCounts := make(map[int]int)
+	var classes []int
+	for i, sample := range X {
+		if _, ok := classMeans[y[i]]; !ok {
+			classMeans[y[i]] = make([]float64, cols)
+			classes = append(classes, y[i])
+		}
+		classCounts[y[i]]++
+		for j, value := range sample {
+			l.Mean[j] += value / float64(rows)
+			classMeans[y[i]][j] += value
+		}
+	}
+	sort.Ints(classes)
+	for _, class := range classes {
+		for j := range classMeans[class] {
+			classMeans[class][j] /= float64(classCounts[class])
+		}
+	}
+
+	if components > len(classes)-1 {
+		components = len(classes) - 1
+	}
+	l.Components = components
+
+	// Within-class and between-class scatter matrices
+	within := make([][]float64, cols)
+	between := make([][]float64, cols)
+	for i := range within {
+		within[i] = make([]float64, cols)
+		between[i] = make([]float64, cols)
+	}
+	for k, sample := range X {
+		classMean := classMeans[y[k]]
+		for i := 0; i < cols; i++ {
+			for j := 0; j < cols; j++ {
+				within[i][j] += (sample[i] - classMean[i]) * (sample[j] - classMean[j])
+			}
+		}
+	}
+
+	// Scale the within-class scatter to the pooled covariance so the projection has unit within-class variance
+	if rows > len(classes) {
+		for i := range within {
+			for j := range within[i] {
+				within[i][j] /= float64(rows - len(classes))
+			}
+		}
+	}
+	for _, class := range classes {
+		classMean := classMeans[class]
+		for i := 0; i < cols; i++ {
+			for j := 0; j < cols; j++ {
+				between[i][j] += float64(classCounts[class]) * (classMean[i] - l.Mean[i]) * (classMean[j] - l.Mean[j])
+			}
+		}
+	}
+
+	// Whitening transform of the within-class scatter, skipping directions with no within-class variance
+	withinValues, withinVectors, _, _ := eigen(within, defaultMaxIter, defaultTol)
+	whitening := make([][]float64, cols)
+	for i := range whitening {
+		whitening[i] = make([]float64, cols)
+		for j := 0; j < cols; j++ {
+			if withinValues[j] > defaultTol {
+				whitening[i][j] = withinVectors[i][j] / math.Sqrt(withinValues[j])
+			}
+		}
+	}
+
+	// Diagonalize the whitened between-class scatter and map its eigenvectors back to feature space
+	values, vectors, _, _ := eigen(matmul(transpose(whitening), matmul(between, whitening)), defaultMaxIter, defaultTol)
+	directions := matmul(whitening, vectors)
+
+	sortedIndices := make([]int, len(values))
+	for i := range sortedIndices {
+		sortedIndices[i] = i
+	}
+	sort.Slice(sortedIndices, func(i, j int) bool { return values[sortedIndices[i]] > values[sortedIndices[j]] })
+
+	totalValue := 0.0
+	for _, value := range values {
+		totalValue += value
+	}
+	l.Vectors = make([][]float64, components)
+	l.ExplainedVarianceRatio = make([]float64, components)
+	for c := 0; c < components; c++ {
+		l.Vectors[c] = make([]float64, cols)
+		for f := 0; f < cols; f++ {
+			l.Vectors[c][f] = directions[f][sortedIndices[c]]
+		}
+		l.ExplainedVarianceRatio[c] = values[sortedIndices[c]] / totalValue
+	}
+}
+
+// Transform projects the input data onto the discriminant directions
+func (l *LDA) Transform(X [][]float64) [][]float64 {
+	transformed := make([][]float64, len(X))
+	for i, sample := range X {
+		transformed[i] = make([]float64, l.Components)
+		for c, vector := range l.Vectors {
+			sum := 0.0
+			for f, value := range sample {
+				sum += (value - l.Mean[f]) * vector[f]
+			}
+			transformed[i][c] = sum
+		}
+	}
+	return transformed
+}
+
+// eigen computes the eigenvalues and eigenvectors of a symmetric matrix using at most maxIter
+// Jacobi rotations, stopping once every off-diagonal element is below tol. It also returns the number
+// of rotations applied and whether the off-diagonal elements were below tol when it stopped.
+func eigen(matrix [][]float64, maxIter int, tol float64) (values []float64, vectors [][]float64, iterations int, converged bool) {
 	cols := len(matrix[0])
 
-	// Initialize eigenvectors matrix
+	// Initialize eigenvectors matrix to the identity, so that it accumulates the rotations
 	vectors = make([][]float64, cols)
 	for i := range vectors {
 		vectors[i] = make([]float64, cols)
+		vectors[i][i] = 1
 	}
 
 	// Initialize values
@@ -149,11 +484,11 @@ func eigen(matrix [][]float64) (values []float64, vectors [][]float64) {
 		copy(temp[i], matrix[i])
 	}
 
-	for i := 0; i < 1000; i++ { // Max iterations
+	for iterations = 0; ; iterations++ {
 		// Find max off-diagonal element
 		p := 0
 		q := 1
-		maxVal := math.Abs(temp[0][1])
+		maxVal := 0.0
 		for j := 0; j < cols; j++ {
 			for k := j + 1; k < cols; k++ {
 				if math.Abs(temp[j][k]) > maxVal {
@@ -165,7 +500,11 @@ func eigen(matrix [][]float64) (values []float64, vectors [][]float64) {
 		}
 
 		// Check convergence
-		if maxVal < 1e-10 {
+		if maxVal < tol {
+			converged = true
+			break
+		}
+		if iterations == maxIter {
 			break
 		}
 
@@ -185,6 +524,8 @@ func eigen(matrix [][]float64) (values []float64, vectors [][]float64) {
 					rot[j][k] = s
 				} else if j == q && k == p {
 					rot[j][k] = -s
+				} else if j == k {
+					rot[j][k] = 1
 				} else {
 					rot[j][k] = 0
 				}
@@ -204,7 +545,78 @@ func eigen(matrix [][]float64) (values []float64, vectors [][]float64) {
 		values[i] = temp[i][i]
 	}
 
-	return values, vectors
+	return values, vectors, iterations, converged
+}
+
+// svd computes the singular values and right singular vectors (the columns of vectors) of a matrix with the
+// one-sided Jacobi method, rotating pairs of columns until every pair is orthogonal to within tol relative
+// to their norms or maxSweeps passes over all pairs have been made. It also returns the number of sweeps
+// and whether the last sweep found every pair orthogonal.
+func svd(matrix [][]float64, maxSweeps int, tol float64) (singularValues []float64, vectors [][]float64, sweeps int, converged bool) {
+	rows := len(matrix)
+	cols := len(matrix[0])
+
+	a := make([][]float64, rows)
+	for i := range a {
+		a[i] = make([]float64, cols)
+		copy(a[i], matrix[i])
+	}
+	vectors = make([][]float64, cols)
+	for i := range vectors {
+		vectors[i] = make([]float64, cols)
+		vectors[i][i] = 1
+	}
+
+	for sweeps = 0; sweeps < maxSweeps && !converged; sweeps++ {
+		rotated := false
+		for i := 0; i < cols-1; i++ {
+			for j := i + 1; j < cols; j++ {
+				alpha, beta, gamma := 0.0, 0.0, 0.0
+				for k := 0; k < rows; k++ {
+					alpha += a[k][i] * a[k][i]
+					beta += a[k][j] * a[k][j]
+					gamma += a[k][i] * a[k][j]
+				}
+				if gamma == 0 || math.Abs(gamma) <= tol*math.Sqrt(alpha*beta) {
+					continue
+				}
+				rotated = true
+
+				// Rotation that makes columns i and j orthogonal
+				zeta := (beta - alpha) / (2 * gamma)
+				t := 1 / (math.Abs(zeta) + math.Sqrt(1+zeta*zeta))
+				if zeta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(1+t*t)
+				s := c * t
+
+				for k := 0; k < rows; k++ {
+					ai, aj := a[k][i], a[k][j]
+					a[k][i] = c*ai - s*aj
+					a[k][j] = s*ai + c*aj
+				}
+				for k := 0; k < cols; k++ {
+					vi, vj := vectors[k][i], vectors[k][j]
+					vectors[k][i] = c*vi - s*vj
+					vectors[k][j] = s*vi + c*vj
+				}
+			}
+		}
+		converged = !rotated
+	}
+
+	// The singular values are the norms of the orthogonalized columns
+	singularValues = make([]float64, cols)
+	for j := 0; j < cols; j++ {
+		sum := 0.0
+		for k := 0; k < rows; k++ {
+			sum += a[k][j] * a[k][j]
+		}
+		singularValues[j] = math.Sqrt(sum)
+	}
+
+	return singularValues, vectors, sweeps, converged
 }
 
 // transpose computes the transpose of a matrix
@@ -275,4 +687,40 @@ func main() {
 
 	// Print explained variance ratio
 	fmt.Println("Explained Variance Ratio:", pca.ExplainedVarianceRatio)
+
+	// Print cumulative explained variance ratio
+	fmt.Println("Cumulative Explained Variance Ratio:", pca.CumulativeExplainedVarianceRatio)
+
+	// Print the information lost by keeping one component
+	fmt.Println("Reconstruction Error:", pca.ReconstructionError(rawData))
+
+	// Report whether the eigen solver converged
+	fmt.Println("Converged:", pca.Converged, "after", pca.Iterations, "rotations")
+
+	// Fit the same components from the SVD of the centered data
+	svdPCA := &PCA{Components: 1}
+	svdPCA.FitSVD(rawData)
+	fmt.Println("SVD Explained Variance:", svdPCA.ExplainedVariance)
+
+	// Fit the same components from batches of two samples
+	incremental := NewIncrementalPCA(1)
+	for start := 0; start < len(rawData); start += 2 {
+		end := start + 2
+		if end > len(rawData) {
+			end = len(rawData)
+		}
+		incremental.PartialFit(rawData[start:end])
+	}
+	if err := incremental.Finalize(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Incremental Explained Variance:", incremental.ExplainedVariance)
+
+	// Project two labelled groups onto their discriminant axis
+	X := [][]float64{{1, 2}, {2, 2}, {1, 3}, {6, 5}, {7, 6}, {6, 6}}
+	y := []int{0, 0, 0, 1, 1, 1}
+	lda := &LDA{}
+	lda.Fit(X, y, 1)
+	fmt.Println("LDA Projection:", lda.Transform(X))
 }
@@ -0,0 +1,264 @@
+package dimensionalityReduction
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// lineData returns n points along direction plus isotropic noise of the given scale
+func lineData(rng *rand.Rand, n int, direction []float64, noise float64) [][]float64 {
+	data := make([][]float64, n)
+	for i := range data {
+		t := rng.NormFloat64() * 5
+		data[i] = make([]float64, len(direction))
+		for j, d := range direction {
+			data[i][j] = t*d + rng.NormFloat64()*noise
+		}
+	}
+	return data
+}
+
+// alignment returns |cos| of the angle between a and b
+func alignment(a, b []float64) float64 {
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	return math.Abs(dot) / math.Sqrt(normA*normB)
+}
+
+// maxCrossCovariance returns the largest absolute covariance between two different columns of data
+func maxCrossCovariance(data [][]float64) float64 {
+	cols := len(data[0])
+	means := make([]float64, cols)
+	for _, row := range data {
+		for j, value := range row {
+			means[j] += value / float64(len(data))
+		}
+	}
+	largest := 0.0
+	for a := 0; a < cols; a++ {
+		for b := a + 1; b < cols; b++ {
+			sum := 0.0
+			for _, row := range data {
+				sum += (row[a] - means[a]) * (row[b] - means[b])
+			}
+			largest = math.Max(largest, math.Abs(sum/float64(len(data)-1)))
+		}
+	}
+	return largest
+}
+
+func TestTighterToleranceDecorrelatesComponents(t *testing.T) {
+	// Two nearly equal variances make the covariance matrix nearly degenerate
+	rng := rand.New(rand.NewSource(1))
+	data := make([][]float64, 500)
+	for i := range data {
+		a, b := rng.NormFloat64(), rng.NormFloat64()*1.001
+		data[i] = []float64{a + b, a - b + 0.01*a, rng.NormFloat64() * 0.1}
+	}
+
+	loose := &PCA{Components: 3, Tol: 1e-2}
+	tight := &PCA{Components: 3, Tol: 1e-14}
+	looseCross := maxCrossCovariance(loose.FitTransform(data))
+	tightCross := maxCrossCovariance(tight.FitTransform(data))
+	if tightCross >= looseCross || tightCross > 1e-10 {
+		t.Errorf("cross-covariance of the components: tight tolerance %v, loose %v", tightCross, looseCross)
+	}
+}
+
+func TestFitRecoversPrincipalAxis(t *testing.T) {
+	// An asymmetric direction catches components stored as columns instead of rows
+	direction := []float64{1, 2, 3}
+	data := lineData(rand.New(rand.NewSource(2)), 300, direction, 0.05)
+
+	pca := &PCA{Components: 1}
+	projected := pca.FitTransform(data)
+	if a := alignment(pca.Vectors[0], direction); a < 0.999 {
+		t.Errorf("first component %v has alignment %v with %v", pca.Vectors[0], a, direction)
+	}
+	if pca.ExplainedVarianceRatio[0] < 0.99 {
+		t.Errorf("first component explains %v of the variance, want nearly all", pca.ExplainedVarianceRatio[0])
+	}
+
+	// The projection is the coordinate along the unit axis
+	norm := math.Sqrt(14)
+	for i, row := range data[:10] {
+		want := 0.0
+		for j, value := range row {
+			want += (value - pca.Mean[j]) * direction[j] / norm
+		}
+		if math.Abs(math.Abs(projected[i][0])-math.Abs(want)) > 1e-3*(1+math.Abs(want)) {
+			t.Errorf("row %d projects to %v, want ±%v", i, projected[i][0], want)
+		}
+	}
+}
+
+func TestIncrementalPCAMatchesFullFit(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	data := make([][]float64, 1000)
+	for i := range data {
+		a, b := rng.NormFloat64()*3, rng.NormFloat64()
+		data[i] = []float64{a + 10, a - b, 2 * b, rng.NormFloat64()*0.5 - 4}
+	}
+
+	full := &PCA{Components: 2}
+	full.Fit(data)
+	incremental := NewIncrementalPCA(2)
+	for start := 0; start < len(data); start += 64 {
+		incremental.PartialFit(data[start:min(start+64, len(data))])
+	}
+	if err := incremental.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	for c := 0; c < 2; c++ {
+		if a := alignment(full.Vectors[c], incremental.Vectors[c]); a < 1-1e-9 {
+			t.Errorf("component %d alignment %v between batch and full fits", c, a)
+		}
+		if math.Abs(full.ExplainedVariance[c]-incremental.ExplainedVariance[c]) > 1e-9 {
+			t.Errorf("component %d variance %v, full fit %v", c, incremental.ExplainedVariance[c], full.ExplainedVariance[c])
+		}
+	}
+}
+
+func TestIncrementalPCAFinalizeNeedsTwoSamples(t *testing.T) {
+	incremental := NewIncrementalPCA(1)
+	if err := incremental.Finalize(); err == nil {
+		t.Error("expected an error before any sample")
+	}
+	incremental.PartialFit([][]float64{{1, 2}})
+	if err := incremental.Finalize(); err == nil {
+		t.Error("expected an error after a single sample")
+	}
+	incremental.PartialFit([][]float64{{3, 5}})
+	if err := incremental.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range incremental.Vectors[0] {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			t.Fatalf("component %v is not finite", incremental.Vectors[0])
+		}
+	}
+}
+
+func TestLDASeparatesBlobs(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	centers := [][]float64{{0, 0, 0}, {8, 2, 0}, {16, -2, 2}}
+	var X [][]float64
+	var y []int
+	for class, center := range centers {
+		for i := 0; i < 50; i++ {
+			X = append(X, []float64{center[0] + rng.NormFloat64(), center[1] + rng.NormFloat64(), center[2] + rng.NormFloat64()})
+			y = append(y, class)
+		}
+	}
+
+	lda := &LDA{}
+	lda.Fit(X, y, 5)
+	if lda.Components != 2 {
+		t.Fatalf("Components = %d, want it capped at numClasses-1 = 2", lda.Components)
+	}
+
+	// On the first axis, the ranges of the classes do not overlap
+	projected := lda.Transform(X)
+	low := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	high := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i, row := range projected {
+		low[y[i]] = math.Min(low[y[i]], row[0])
+		high[y[i]] = math.Max(high[y[i]], row[0])
+	}
+	for a := 0; a < 3; a++ {
+		for b := a + 1; b < 3; b++ {
+			if low[a] < high[b] && low[b] < high[a] {
+				t.Errorf("classes %d [%v, %v] and %d [%v, %v] overlap on the first axis", a, low[a], high[a], b, low[b], high[b])
+			}
+		}
+	}
+}
+
+func TestFitReportsNonConvergence(t *testing.T) {
+	data := [][]float64{{1, 2, 0, 5}, {3, 1, 4, 1}, {0, 5, 2, 2}, {4, 4, 1, 0}, {2, 0, 3, 3}}
+
+	capped := &PCA{Components: 2, MaxIter: 2}
+	capped.Fit(data)
+	if capped.Converged || capped.Iterations != 2 {
+		t.Errorf("Converged = %v after %d iterations, want false after 2", capped.Converged, capped.Iterations)
+	}
+
+	pca := &PCA{Components: 2}
+	pca.Fit(data)
+	if !pca.Converged {
+		t.Errorf("Converged = false after %d iterations with the default limit", pca.Iterations)
+	}
+}
+
+func TestFitSVDMatchesFitAndIsMoreAccurate(t *testing.T) {
+	// On well-conditioned data both fits find the same components
+	rng := rand.New(rand.NewSource(4))
+	data := lineData(rng, 300, []float64{1, -2, 0.5}, 0.5)
+	covariance := &PCA{Components: 3}
+	covariance.Fit(data)
+	svd := &PCA{Components: 3}
+	svd.FitSVD(data)
+	for c := 0; c < 3; c++ {
+		if a := alignment(covariance.Vectors[c], svd.Vectors[c]); a < 1-1e-9 {
+			t.Errorf("component %d alignment %v between Fit and FitSVD", c, a)
+		}
+		if math.Abs(covariance.ExplainedVariance[c]-svd.ExplainedVariance[c]) > 1e-9*(1+covariance.ExplainedVariance[c]) {
+			t.Errorf("component %d variance %v with Fit, %v with FitSVD", c, covariance.ExplainedVariance[c], svd.ExplainedVariance[c])
+		}
+	}
+
+	// Two nearly collinear features: the variance across the shared direction is 1e-18 of the variance along it,
+	// below the precision left after forming the covariance matrix
+	collinear := make([][]float64, 200)
+	across := make([]float64, len(collinear))
+	for i := range collinear {
+		big, small := rng.NormFloat64()*1e4, rng.NormFloat64()*1e-5
+		collinear[i] = []float64{big + small, big - small}
+		across[i] = (collinear[i][0] - collinear[i][1]) / math.Sqrt2
+	}
+	mean := 0.0
+	for _, value := range across {
+		mean += value / float64(len(across))
+	}
+	want := 0.0
+	for _, value := range across {
+		want += (value - mean) * (value - mean) / float64(len(across)-1)
+	}
+
+	covariance = &PCA{Components: 2}
+	covariance.Fit(collinear)
+	svd = &PCA{Components: 2}
+	svd.FitSVD(collinear)
+	svdError := math.Abs(svd.ExplainedVariance[1]-want) / want
+	covarianceError := math.Abs(covariance.ExplainedVariance[1]-want) / want
+	if svdError > 0.05 {
+		t.Errorf("FitSVD smallest variance %v, want %v", svd.ExplainedVariance[1], want)
+	}
+	if covarianceError < 10*svdError {
+		t.Errorf("Fit relative error %v not clearly worse than FitSVD's %v", covarianceError, svdError)
+	}
+}
+
+func TestReconstructionErrorWithAllComponents(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	data := lineData(rng, 200, []float64{2, 1, -1}, 0.3)
+
+	all := &PCA{Components: 3}
+	all.Fit(data)
+	if err := all.ReconstructionError(data); err > 1e-20 {
+		t.Errorf("reconstruction error with every component %v, want about 0", err)
+	}
+
+	// Dropping two components loses their noise variance of about 2 * 0.3², averaged over the three features
+	one := &PCA{Components: 1}
+	one.Fit(data)
+	if err := one.ReconstructionError(data); err < 0.01 || err > 0.2 {
+		t.Errorf("reconstruction error with one component %v, want about 0.06", err)
+	}
+}
@@ -0,0 +1,268 @@
+package ensemble
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"ml/hyperparameterTuning"
+)
+
+// Classifier represents a trained model that predicts a class label for a sample
+type Classifier interface {
+	Predict(sample []float64) float64
+}
+
+// ProbabilisticClassifier represents a classifier that also reports the probability of each class
+type ProbabilisticClassifier interface {
+	Classifier
+	PredictProba(sample []float64) map[float64]float64
+}
+
+// ClassifierFunc adapts a prediction function, such as a method of one of the models in this repository, to a Classifier
+type ClassifierFunc func(sample []float64) float64
+
+// Predict calls f(sample)
+func (f ClassifierFunc) Predict(sample []float64) float64 {
+	return f(sample)
+}
+
+// Estimator represents a model that can be trained and then predict a value for a sample
+type Estimator interface {
+	Fit(X [][]float64, y []float64)
+	Predict(sample []float64) float64
+}
+
+// VotingClassifier combines the predictions of several classifiers
+type VotingClassifier struct {
+	Estimators []Classifier
+	Weights    []float64 // Weight of each estimator; nil weighs them equally
+	Voting     string    // "hard" counts predicted labels, "soft" averages class probabilities
+}
+
+// NewVotingClassifier creates a new voting classifier with equally weighted estimators
+func NewVotingClassifier(estimators []Classifier, voting string) *VotingClassifier {
+	return &VotingClassifier{
+		Estimators: estimators,
+		Voting:     voting,
+	}
+}
+
+// Validate checks that the classifier has estimators and that Weights, if set, has one non-negative weight per estimator
+// and a positive total
+func (vc *VotingClassifier) Validate() error {
+	if len(vc.Estimators) == 0 {
+		return fmt.Errorf("voting classifier has no estimators")
+	}
+	if vc.Weights == nil {
+		return nil
+	}
+	if len(vc.Weights) != len(vc.Estimators) {
+		return fmt.Errorf("got %d weights for %d estimators", len(vc.Weights), len(vc.Estimators))
+	}
+	totalWeight := 0.0
+	for i, weight := range vc.Weights {
+		if !(weight >= 0) || math.IsInf(weight, 1) {
+			return fmt.Errorf("weight %d is %v, want a finite non-negative value", i, weight)
+		}
+		totalWeight += weight
+	}
+	if totalWeight <= 0 {
+		return fmt.Errorf("weights sum to %v, want a positive total", totalWeight)
+	}
+	return nil
+}
+
+// Predict returns the class with the largest weighted vote (hard) or averaged probability (soft).
+// Ties go to the smaller class label. It returns NaN if Validate fails.
+func (vc *VotingClassifier) Predict(sample []float64) float64 {
+	bestClass := math.NaN()
+	bestScore := -1.0
+	for class, score := range vc.PredictProba(sample) {
+		if score > bestScore || (score == bestScore && class < bestClass) {
+			bestClass = class
+			bestScore = score
+		}
+	}
+	return bestClass
+}
+
+// PredictProba returns the weighted share of votes (hard) or the weighted average probability (soft) of each class.
+// With soft voting, estimators that do not report probabilities contribute all their weight to their predicted class.
+// It returns an empty map if Validate fails.
+func (vc *VotingClassifier) PredictProba(sample []float64) map[float64]float64 {
+	scores := make(map[float64]float64)
+	if vc.Validate() != nil {
+		return scores
+	}
+	totalWeight := 0.0
+	for i, estimator := range vc.Estimators {
+		weight := 1.0
+		if vc.Weights != nil {
+			weight = vc.Weights[i]
+		}
+		totalWeight += weight
+
+		if probabilistic, ok := estimator.(ProbabilisticClassifier); ok && vc.Voting == "soft" {
+			for class, prob := range probabilistic.PredictProba(sample) {
+				scores[class] += weight * prob
+			}
+		} else {
+			scores[estimator.Predict(sample)] += weight
+		}
+	}
+
+	for class := range scores {
+		scores[class] /= totalWeight
+	}
+	return scores
+}
+
+// StackingClassifier trains a meta-estimator on the out-of-fold predictions of several base estimators
+type StackingClassifier struct {
+	BaseEstimators []Estimator
+	MetaEstimator  Estimator
+	NumFolds       int
+	Seed           int64 // Seed for shuffling the rows into folds
+}
+
+// NewStackingClassifier creates a new stacking classifier
+func NewStackingClassifier(baseEstimators []Estimator, metaEstimator Estimator, numFolds int) *StackingClassifier {
+	return &StackingClassifier{
+		BaseEstimators: baseEstimators,
+		MetaEstimator:  metaEstimator,
+		NumFolds:       numFolds,
+	}
+}
+
+// Fit trains the base estimators on each fold's complement to predict that fold, so every meta-feature comes from
+// a model that never saw the row, then trains the meta-estimator on those predictions and refits the base estimators on all of X.
+// The rows are shuffled into NumFolds folds seeded with Seed; NumFolds must be between 2 and len(X).
+func (sc *StackingClassifier) Fit(X [][]float64, y []float64) error {
+	if sc.NumFolds < 2 {
+		return fmt.Errorf("NumFolds must be at least 2, got %d", sc.NumFolds)
+	}
+	if sc.NumFolds > len(X) {
+		return fmt.Errorf("NumFolds %d exceeds the %d samples", sc.NumFolds, len(X))
+	}
+
+	metaFeatures := make([][]float64, len(X))
+	for i := range metaFeatures {
+		metaFeatures[i] = make([]float64, len(sc.BaseEstimators))
+	}
+
+	for _, validIndices := range hyperparameterTuning.KFold(len(X), sc.NumFolds, rand.New(rand.NewSource(sc.Seed))) {
+		XTrain, yTrain := complement(X, y, validIndices)
+		for b, estimator := range sc.BaseEstimators {
+			estimator.Fit(XTrain, yTrain)
+			for _, i := range validIndices {
+				metaFeatures[i][b] = estimator.Predict(X[i])
+			}
+		}
+	}
+
+	for _, estimator := range sc.BaseEstimators {
+		estimator.Fit(X, y)
+	}
+	sc.MetaEstimator.Fit(metaFeatures, y)
+	return nil
+}
+
+// Predict feeds the predictions of the base estimators to the meta-estimator
+func (sc *StackingClassifier) Predict(sample []float64) float64 {
+	features := make([]float64, len(sc.BaseEstimators))
+	for b, estimator := range sc.BaseEstimators {
+		features[b] = estimator.Predict(sample)
+	}
+	return sc.MetaEstimator.Predict(features)
+}
+
+// complement returns the rows of X and y whose indices are not in the sorted slice excluded
+func complement(X [][]float64, y []float64, excluded []int) ([][]float64, []float64) {
+	var XRest [][]float64
+	var yRest []float64
+	next := 0
+	for i := range X {
+		if next < len(excluded) && excluded[next] == i {
+			next++
+			continue
+		}
+		XRest = append(XRest, X[i])
+		yRest = append(yRest, y[i])
+	}
+	return XRest, yRest
+}
+
+// stump is a one-feature threshold classifier used in the example below
+type stump struct {
+	feature   int
+	threshold float64
+}
+
+// Fit places the threshold halfway between the mean feature values of the two classes
+func (s *stump) Fit(X [][]float64, y []float64) {
+	var sum [2]float64
+	var count [2]int
+	for i, sample := range X {
+		class := 0
+		if y[i] > 0 {
+			class = 1
+		}
+		sum[class] += sample[s.feature]
+		count[class]++
+	}
+	s.threshold = (sum[0]/float64(count[0]) + sum[1]/float64(count[1])) / 2
+}
+
+// Predict returns 1 above the threshold and 0 otherwise
+func (s *stump) Predict(sample []float64) float64 {
+	if sample[s.feature] > s.threshold {
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	// Simple threshold classifiers on different features
+	estimators := []Classifier{
+		ClassifierFunc(func(sample []float64) float64 {
+			if sample[0] > 0.5 {
+				return 1
+			}
+			return 0
+		}),
+		ClassifierFunc(func(sample []float64) float64 {
+			if sample[1] > 0.5 {
+				return 1
+			}
+			return 0
+		}),
+		ClassifierFunc(func(sample []float64) float64 {
+			if sample[0]+sample[1] > 1 {
+				return 1
+			}
+			return 0
+		}),
+	}
+
+	// Combine them with a hard vote
+	voting := NewVotingClassifier(estimators, "hard")
+
+	// Predict new samples
+	samples := [][]float64{{0.2, 0.9}, {0.7, 0.6}, {0.1, 0.3}}
+	for _, sample := range samples {
+		fmt.Printf("Prediction for %v: %v (votes: %v)\n", sample, voting.Predict(sample), voting.PredictProba(sample))
+	}
+
+	// Stack two stumps under a third one
+	X := [][]float64{{0.1, 0.2}, {0.3, 0.1}, {0.2, 0.4}, {0.8, 0.7}, {0.9, 0.6}, {0.7, 0.9}}
+	y := []float64{0, 0, 0, 1, 1, 1}
+	stacking := NewStackingClassifier([]Estimator{&stump{feature: 0}, &stump{feature: 1}}, &stump{feature: 0}, 3)
+	if err := stacking.Fit(X, y); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	for _, sample := range samples {
+		fmt.Printf("Stacked prediction for %v: %v\n", sample, stacking.Predict(sample))
+	}
+}
@@ -0,0 +1,159 @@
+package ensemble
+
+import (
+	"math"
+	"testing"
+)
+
+// constant returns a classifier that always predicts label
+func constant(label float64) Classifier {
+	return ClassifierFunc(func(sample []float64) float64 { return label })
+}
+
+// fixedProba is a probabilistic classifier with fixed class probabilities
+type fixedProba map[float64]float64
+
+func (p fixedProba) Predict(sample []float64) float64 {
+	var bestClass float64
+	bestProb := -1.0
+	for class, prob := range p {
+		if prob > bestProb {
+			bestClass, bestProb = class, prob
+		}
+	}
+	return bestClass
+}
+
+func (p fixedProba) PredictProba(sample []float64) map[float64]float64 {
+	return p
+}
+
+func TestHardVoteMajority(t *testing.T) {
+	vc := NewVotingClassifier([]Classifier{constant(1), constant(2), constant(2)}, "hard")
+	if got := vc.Predict(nil); got != 2 {
+		t.Errorf("Predict = %v, want the majority label 2", got)
+	}
+	if got := vc.PredictProba(nil)[2]; math.Abs(got-2.0/3) > 1e-12 {
+		t.Errorf("vote share of 2 = %v, want 2/3", got)
+	}
+}
+
+func TestWeightedVoteOverridesMajority(t *testing.T) {
+	vc := NewVotingClassifier([]Classifier{constant(1), constant(2), constant(2)}, "hard")
+	vc.Weights = []float64{3, 1, 1}
+	if got := vc.Predict(nil); got != 1 {
+		t.Errorf("Predict = %v, want the heavily weighted label 1", got)
+	}
+}
+
+func TestSoftVoteAveragesProbabilities(t *testing.T) {
+	// Hard voting would pick 0, but the average probability of 1 is higher
+	vc := NewVotingClassifier([]Classifier{
+		fixedProba{0: 0.6, 1: 0.4},
+		fixedProba{0: 0.6, 1: 0.4},
+		fixedProba{0: 0.1, 1: 0.9},
+	}, "soft")
+	proba := vc.PredictProba(nil)
+	if math.Abs(proba[1]-1.7/3) > 1e-12 || math.Abs(proba[0]-1.3/3) > 1e-12 {
+		t.Errorf("PredictProba = %v, want 0: 1.3/3, 1: 1.7/3", proba)
+	}
+	if got := vc.Predict(nil); got != 1 {
+		t.Errorf("Predict = %v, want 1", got)
+	}
+}
+
+func TestVoteTieGoesToSmallerLabel(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		vc := NewVotingClassifier([]Classifier{constant(3), constant(1), constant(2), constant(5)}, "hard")
+		if got := vc.Predict(nil); got != 1 {
+			t.Fatalf("Predict = %v, want the smallest tied label 1", got)
+		}
+	}
+}
+
+func TestValidateRejectsBadWeights(t *testing.T) {
+	estimators := []Classifier{constant(0), constant(1)}
+	for _, weights := range [][]float64{{1}, {0, 0}, {1, -1}, {1, math.NaN()}} {
+		vc := NewVotingClassifier(estimators, "hard")
+		vc.Weights = weights
+		if err := vc.Validate(); err == nil {
+			t.Errorf("weights %v: expected an error", weights)
+		}
+		if got := vc.Predict(nil); !math.IsNaN(got) {
+			t.Errorf("weights %v: Predict = %v, want NaN", weights, got)
+		}
+	}
+	if err := NewVotingClassifier(nil, "hard").Validate(); err == nil {
+		t.Error("expected an error for no estimators")
+	}
+}
+
+// memorizer predicts 1 for the rows it was fitted on and 0 for any other row
+type memorizer struct {
+	seen map[[2]float64]bool
+}
+
+func (m *memorizer) Fit(X [][]float64, y []float64) {
+	m.seen = make(map[[2]float64]bool)
+	for _, sample := range X {
+		m.seen[[2]float64{sample[0], sample[1]}] = true
+	}
+}
+
+func (m *memorizer) Predict(sample []float64) float64 {
+	if m.seen[[2]float64{sample[0], sample[1]}] {
+		return 1
+	}
+	return 0
+}
+
+// recorder keeps the features it was fitted on
+type recorder struct {
+	X [][]float64
+}
+
+func (r *recorder) Fit(X [][]float64, y []float64) {
+	r.X = X
+}
+
+func (r *recorder) Predict(sample []float64) float64 {
+	return 0
+}
+
+func TestStackingMetaFeaturesAreOutOfFold(t *testing.T) {
+	var X [][]float64
+	var y []float64
+	for i := 0; i < 12; i++ {
+		X = append(X, []float64{float64(i), float64(i * i)})
+		y = append(y, float64(i/6))
+	}
+	meta := &recorder{}
+	stacking := NewStackingClassifier([]Estimator{&memorizer{}}, meta, 4)
+	if err := stacking.Fit(X, y); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(meta.X) != len(X) {
+		t.Fatalf("meta-estimator fitted on %d rows, want %d", len(meta.X), len(X))
+	}
+	for i, features := range meta.X {
+		if features[0] != 0 {
+			t.Errorf("meta-feature of row %d comes from a base model fitted on that row", i)
+		}
+	}
+	// The base estimators are refitted on all rows afterwards
+	if got := stacking.BaseEstimators[0].Predict(X[0]); got != 1 {
+		t.Errorf("refitted base estimator predicted %v for a training row, want 1", got)
+	}
+}
+
+func TestStackingFitRejectsInvalidFolds(t *testing.T) {
+	X := [][]float64{{0, 0}, {1, 1}, {2, 2}}
+	y := []float64{0, 1, 0}
+	for _, numFolds := range []int{0, 1, 4} {
+		stacking := NewStackingClassifier([]Estimator{&memorizer{}}, &recorder{}, numFolds)
+		if err := stacking.Fit(X, y); err == nil {
+			t.Errorf("NumFolds %d: expected an error", numFolds)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import(
 type FeatureSelectionResult struct {
 	FeatureIndices []int
 	Scores         []float64
+	ConstantFeatures []int // Zero-variance features, which are excluded from ranking
 }
 
 // loadData loads data from a CSV file
@@ -51,30 +52,46 @@ func univariateFeatureSelection(X [][]float64, y []float64, numFeatures int) Fea
 	numSamples := len(X)
 	numFeaturesAll := len(X[0])
 	scores := make([]float64, numFeaturesAll)
+	var rankedIndices, constantIndices []int
 
 	for i := 0; i < numFeaturesAll; i++ {
 		featureValues := make([]float64, numSamples)
 		for j := 0; j < numSamples; j++ {
 			featureValues[j] = X[j][i]
 		}
+		// A constant feature carries no information, so report it instead of scoring it
+		if isConstant(featureValues) {
+			constantIndices = append(constantIndices, i)
+			continue
+		}
 		scores[i] = calculateScore(featureValues, y)
+		rankedIndices = append(rankedIndices, i)
 	}
 
 	// Rank features based on scores
-	rankedIndices := make([]int, numFeaturesAll)
-	for i := range rankedIndices {
-		rankedIndices[i] = i
-	}
 	sortIndicesByScores(rankedIndices, scores)
 
 	// Select top k features
+	if numFeatures > len(rankedIndices) {
+		numFeatures = len(rankedIndices)
+	}
 	selectedIndices := rankedIndices[:numFeatures]
 	selectedScores := make([]float64, numFeatures)
 	for i, index := range selectedIndices {
 		selectedScores[i] = scores[index]
 	}
 
-	return FeatureSelectionResult{FeatureIndices: selectedIndices, Scores: selectedScores}
+	return FeatureSelectionResult{FeatureIndices: selectedIndices, Scores: selectedScores, ConstantFeatures: constantIndices}
+}
+
+// isConstant reports whether every value is the same
+func isConstant(values []float64) bool {
+	for _, v := range values {
+		if v != values[0] {
+			return false
+		}
+	}
+	return true
 }
 
 // calculateScore calculates the score for a feature
@@ -93,15 +110,19 @@ func correlationCoefficient(x, y []float64) float64 {
 		return math.NaN()
 	}
 
-	n := len(x)
-	meanX := mean(x)
-	meanY := mean(y)
-	var numerator, denomX, denomY float64
-
-	for i := 0; i < n; i++ {
-		numerator += (x[i] - meanX) * (y[i] - meanY)
-		denomX += math.Pow(x[i]-meanX, 2)
-		denomY += math.Pow(y[i]-meanY, 2)
+	// Accumulate the co-moment and sums of squared deviations in a single pass with Welford's updates,
+	// which avoids the cancellation of sum(x*y) - n*meanX*meanY on large-magnitude data
+	var meanX, meanY, numerator, denomX, denomY float64
+
+	for i := range x {
+		n := float64(i + 1)
+		dx := x[i] - meanX
+		dy := y[i] - meanY
+		meanX += dx / n
+		meanY += dy / n
+		numerator += dx * (y[i] - meanY)
+		denomX += dx * (x[i] - meanX)
+		denomY += dy * (y[i] - meanY)
 	}
 
 	denominator := math.Sqrt(denomX * denomY)
@@ -112,15 +133,6 @@ func correlationCoefficient(x, y []float64) float64 {
 	return numerator / denominator
 }
 
-// mean calculates the mean of a slice of values
-func mean(values []float64) float64 {
-	sum := 0.0
-	for _, v := range values {
-		sum += v
-	}
-	return sum / float64(len(values))
-}
-
 // sortIndicesByScores sorts feature indices based on their scores
 func sortIndicesByScores(indices []int, scores []float64) {
 	sort.Slice(indices, func(i, j int) bool {
@@ -0,0 +1,36 @@
+package featureSelection
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConstantFeaturesAreReported(t *testing.T) {
+	X := [][]float64{{1, 5, 0.1}, {2, 5, 0.4}, {3, 5, 0.2}, {4, 5, 0.3}}
+	y := []float64{2, 4, 6, 8}
+	result := univariateFeatureSelection(X, y, 3)
+
+	if len(result.ConstantFeatures) != 1 || result.ConstantFeatures[0] != 1 {
+		t.Errorf("ConstantFeatures = %v, want [1]", result.ConstantFeatures)
+	}
+	for _, index := range result.FeatureIndices {
+		if index == 1 {
+			t.Errorf("constant feature ranked in %v", result.FeatureIndices)
+		}
+	}
+	if len(result.FeatureIndices) != 2 || result.FeatureIndices[0] != 0 {
+		t.Errorf("FeatureIndices = %v, want feature 0 first of 2", result.FeatureIndices)
+	}
+}
+
+func TestCorrelationOnLargeOffsets(t *testing.T) {
+	// y = 2x exactly, far from the origin, where sum(x*y) - n*meanX*meanY cancels catastrophically
+	var x, y []float64
+	for i := 0; i < 1000; i++ {
+		x = append(x, 1e9+float64(i%17))
+		y = append(y, 2e9+2*float64(i%17))
+	}
+	if r := correlationCoefficient(x, y); math.Abs(r-1) > 1e-9 {
+		t.Errorf("correlation = %v, want 1", r)
+	}
+}
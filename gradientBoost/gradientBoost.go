@@ -3,21 +3,31 @@ package gradientBoost
 import(
 	"fmt"
 	"math"
+	"math/rand"
 )
 
 type GradientBoosting struct {
 	Trees         []*RegressionTree
 	LearningRate float64
+	InitialPrediction float64 // Mean of the training targets that the trees correct
+	Oblique       bool  // Also consider splits on a random linear combination of two features at every node
+	Seed          int64 // Seed for the random feature pairs and weights of oblique splits
+	rng           *rand.Rand
 }
 
 type RegressionTree struct {
-	Root *Node
+	Root      *Node
+	NumLeaves int
 }
 
 type Node struct {
 	FeatureIndex int
 	Threshold    float64
+	Oblique      bool       // Split on Weights[0]*sample[FeatureIndex] + Weights[1]*sample[FeatureIndex2] instead of sample[FeatureIndex]
+	FeatureIndex2 int
+	Weights      [2]float64
 	Value        float64
+	LeafID       int // Index of the leaf within its tree, in the order leaves were built
 	Left         *Node
 	Right        *Node
 }
@@ -34,6 +44,8 @@ func (gb *GradientBoosting) Train(X [][]float64, y []float64, numIterations int)
 
 	// Initialize predictions with the mean of y
 	mean := calculateMean(y)
+	gb.InitialPrediction = mean
+	gb.rng = rand.New(rand.NewSource(gb.Seed))
 	for i := range predictions {
 		predictions[i] = mean
 	}
@@ -76,13 +88,13 @@ func calculateResiduals(y, predictions []float64) []float64 {
 
 func (gb *GradientBoosting) trainRegressionTree(X [][]float64, y []float64) *RegressionTree {
 	tree := &RegressionTree{}
-	tree.Root = gb.buildTree(X, y, 0)
+	tree.Root = gb.buildTree(tree, X, y, 0)
 	return tree
 }
 
-func (gb *GradientBoosting) buildTree(X [][]float64, y []float64, depth int) *Node {
-	if depth >= 2 {
-		return &Node{Value: calculateMean(y)}
+func (gb *GradientBoosting) buildTree(tree *RegressionTree, X [][]float64, y []float64, depth int) *Node {
+	if depth >= 2 || len(X) <= 1 || len(X[0]) == 0 {
+		return tree.newLeaf(calculateMean(y))
 	}
 
 	bestFeatureIndex := 0
@@ -104,16 +116,74 @@ func (gb *GradientBoosting) buildTree(X [][]float64, y []float64, depth int) *No
 		}
 	}
 
-	leftX, leftY, rightX, rightY := splitData(X, y, bestFeatureIndex, bestThreshold)
-	leftNode := gb.buildTree(leftX, leftY, depth+1)
-	rightNode := gb.buildTree(rightX, rightY, depth+1)
-
-	return &Node{
+	node := &Node{
 		FeatureIndex: bestFeatureIndex,
 		Threshold:    bestThreshold,
-		Left:         leftNode,
-		Right:        rightNode,
 	}
+
+	// Try every threshold on a random combination of two features and keep it if it reduces the variance more
+	if gb.Oblique && numFeatures >= 2 {
+		feature1 := gb.rng.Intn(numFeatures)
+		feature2 := gb.rng.Intn(numFeatures - 1)
+		if feature2 >= feature1 {
+			feature2++
+		}
+		oblique := &Node{
+			Oblique:       true,
+			FeatureIndex:  feature1,
+			FeatureIndex2: feature2,
+			Weights:       [2]float64{gb.rng.NormFloat64(), gb.rng.NormFloat64()},
+		}
+		for j := 0; j < numSamples; j++ {
+			oblique.Threshold = oblique.splitValue(X[j])
+			_, leftY, _, rightY := oblique.split(X, y)
+			score := calculateScore(leftY, rightY)
+			if score < bestScore {
+				bestThreshold = oblique.Threshold
+				bestScore = score
+				node = oblique
+			}
+		}
+		node.Threshold = bestThreshold
+	}
+
+	leftX, leftY, rightX, rightY := node.split(X, y)
+	if len(leftY) == 0 || len(rightY) == 0 {
+		return tree.newLeaf(calculateMean(y))
+	}
+	node.Left = gb.buildTree(tree, leftX, leftY, depth+1)
+	node.Right = gb.buildTree(tree, rightX, rightY, depth+1)
+
+	return node
+}
+
+// splitValue returns the value of the sample that the node compares against its threshold
+func (node *Node) splitValue(sample []float64) float64 {
+	if node.Oblique {
+		return node.Weights[0]*sample[node.FeatureIndex] + node.Weights[1]*sample[node.FeatureIndex2]
+	}
+	return sample[node.FeatureIndex]
+}
+
+// split divides X and y by the node's split value and threshold
+func (node *Node) split(X [][]float64, y []float64) (leftX [][]float64, leftY []float64, rightX [][]float64, rightY []float64) {
+	for i := range X {
+		if node.splitValue(X[i]) < node.Threshold {
+			leftX = append(leftX, X[i])
+			leftY = append(leftY, y[i])
+		} else {
+			rightX = append(rightX, X[i])
+			rightY = append(rightY, y[i])
+		}
+	}
+	return
+}
+
+// newLeaf creates a leaf with the next free leaf id of the tree
+func (tree *RegressionTree) newLeaf(value float64) *Node {
+	leaf := &Node{Value: value, LeafID: tree.NumLeaves}
+	tree.NumLeaves++
+	return leaf
 }
 
 func calculateScore(leftY, rightY []float64) float64 {
@@ -130,19 +200,85 @@ func calculateScore(leftY, rightY []float64) float64 {
 	return score
 }
 
+// Predict returns InitialPrediction plus the scaled corrections of every tree.
+// Trees are fitted to residuals from InitialPrediction, so starting from 0 would offset every prediction by the training mean.
 func (gb *GradientBoosting) Predict(sample []float64) float64 {
-	prediction := 0.0
+	prediction := gb.InitialPrediction
 	for _, tree := range gb.Trees {
 		prediction += gb.LearningRate * tree.Root.traverseTree(sample)
 	}
 	return prediction
 }
 
+// PredictBatch predicts the target for every row of X
+func (gb *GradientBoosting) PredictBatch(X [][]float64) []float64 {
+	predictions := make([]float64, len(X))
+	for i, sample := range X {
+		predictions[i] = gb.Predict(sample)
+	}
+	return predictions
+}
+
+// Score returns the coefficient of determination (R²) of the predictions for X against y.
+// R² is undefined when y is constant, so it then returns 1 for perfect predictions and 0 otherwise;
+// it returns NaN for empty y.
+func (gb *GradientBoosting) Score(X [][]float64, y []float64) float64 {
+	if len(y) == 0 {
+		return math.NaN()
+	}
+	predictions := gb.PredictBatch(X)
+	mean := calculateMean(y)
+
+	var residualSum, totalSum float64
+	for i := range y {
+		residualSum += math.Pow(y[i]-predictions[i], 2)
+		totalSum += math.Pow(y[i]-mean, 2)
+	}
+	if totalSum == 0 {
+		if residualSum == 0 {
+			return 1
+		}
+		return 0
+	}
+	return 1 - residualSum/totalSum
+}
+
+// AdjustedScore returns R² adjusted for the number of features in X.
+// The adjustment needs more rows than features plus one, so it returns NaN for smaller or empty X.
+func (gb *GradientBoosting) AdjustedScore(X [][]float64, y []float64) float64 {
+	if len(X) == 0 || len(X) <= len(X[0])+1 {
+		return math.NaN()
+	}
+	n := float64(len(X))
+	p := float64(len(X[0]))
+	return 1 - (1-gb.Score(X, y))*(n-1)/(n-p-1)
+}
+
+// Apply returns the id of the leaf the sample reaches in each tree, e.g. to one-hot encode as features for a linear model
+func (gb *GradientBoosting) Apply(sample []float64) []int {
+	leaves := make([]int, len(gb.Trees))
+	for i, tree := range gb.Trees {
+		leaves[i] = tree.Root.leaf(sample).LeafID
+	}
+	return leaves
+}
+
+// leaf returns the leaf reached by the sample
+func (node *Node) leaf(sample []float64) *Node {
+	if node.Left == nil && node.Right == nil {
+		return node
+	}
+	if node.splitValue(sample) < node.Threshold {
+		return node.Left.leaf(sample)
+	}
+	return node.Right.leaf(sample)
+}
+
 func (node *Node) traverseTree(sample []float64) float64 {
 	if node.Left == nil && node.Right == nil {
 		return node.Value
 	}
-	if sample[node.FeatureIndex] < node.Threshold {
+	if node.splitValue(sample) < node.Threshold {
 		return node.Left.traverseTree(sample)
 	}
 	return node.Right.traverseTree(sample)
@@ -177,4 +313,5 @@ func main() {
 	for _, sample := range X {
 		fmt.Println(gb.Predict(sample))
 	}
+	fmt.Println("R²:", gb.Score(X, y))
 }
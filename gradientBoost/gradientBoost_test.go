@@ -0,0 +1,67 @@
+package gradientBoost
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	var X [][]float64
+	var y []float64
+	for i := 0; i < 30; i++ {
+		x := float64(i)
+		X = append(X, []float64{x})
+		y = append(y, 100+3*x)
+	}
+	gb := NewGradientBoosting(0.5)
+	gb.Train(X, y, 50)
+
+	// Predictions start from the training mean, so a well-fitted model scores close to 1
+	if score := gb.Score(X, y); score < 0.95 {
+		t.Errorf("Score = %v, want close to 1", score)
+	}
+	if adjusted := gb.AdjustedScore(X, y); adjusted > gb.Score(X, y) {
+		t.Errorf("AdjustedScore %v exceeds Score %v", adjusted, gb.Score(X, y))
+	}
+}
+
+func TestScoreDegenerateInput(t *testing.T) {
+	X := [][]float64{{1}, {2}, {3}}
+	constant := []float64{5, 5, 5}
+	gb := NewGradientBoosting(0.5)
+	gb.Train(X, constant, 5)
+
+	if score := gb.Score(X, constant); score != 1 {
+		t.Errorf("Score on constant targets predicted exactly = %v, want 1", score)
+	}
+	if score := gb.Score(X, []float64{6, 6, 6}); score != 0 {
+		t.Errorf("Score on constant targets predicted badly = %v, want 0", score)
+	}
+	if score := gb.AdjustedScore(nil, nil); !math.IsNaN(score) {
+		t.Errorf("AdjustedScore on empty X = %v, want NaN", score)
+	}
+	if score := gb.AdjustedScore(X[:2], constant[:2]); !math.IsNaN(score) {
+		t.Errorf("AdjustedScore with too few rows = %v, want NaN", score)
+	}
+}
+
+func TestTrainTinyDatasets(t *testing.T) {
+	// A single row gives leaves predicting its target
+	gb := NewGradientBoosting(0.5)
+	gb.Train([][]float64{{1, 2}}, []float64{3}, 5)
+	if prediction := gb.Predict([]float64{7, 7}); prediction != 3 {
+		t.Errorf("single-row model predicts %v, want 3", prediction)
+	}
+
+	// Rows without features
+	gb.Train([][]float64{{}, {}}, []float64{1, 3}, 5)
+	if prediction := gb.Predict([]float64{}); prediction != 2 {
+		t.Errorf("featureless model predicts %v, want the mean 2", prediction)
+	}
+
+	// A constant target leaves nothing for the trees to correct
+	gb.Train([][]float64{{1}, {2}, {3}}, []float64{4, 4, 4}, 5)
+	if prediction := gb.Predict([]float64{2}); prediction != 4 {
+		t.Errorf("constant-target model predicts %v, want 4", prediction)
+	}
+}
@@ -36,35 +36,75 @@ func centroid(points [][]float64) []float64 {
 	return center
 }
 
+// DistanceMatrix computes the distance between every pair of points using the given metric
+func DistanceMatrix(data [][]float64, metric func([]float64, []float64) float64) [][]float64 {
+	matrix := make([][]float64, len(data))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(data))
+	}
+	for i := range data {
+		for j := i + 1; j < len(data); j++ {
+			d := metric(data[i], data[j])
+			matrix[i][j] = d
+			matrix[j][i] = d
+		}
+	}
+	return matrix
+}
+
 func agglomerativeClustering(data [][]float64, k int) [][]int {
-	clusters := make([]Cluster, len(data))
-	for i := range clusters {
-		clusters[i].Points = [][]float64{data[i]}
-		clusters[i].Center = data[i]
+	slots := make([]Cluster, len(data))
+	for i := range slots {
+		slots[i].Points = [][]float64{data[i]}
+		slots[i].Center = data[i]
+	}
+
+	// Distances between the centers of the clusters in each pair of slots; a merged cluster reuses
+	// the slot of its first half, so only its row and column change after each merge
+	distances := DistanceMatrix(data, distance)
+	active := make([]bool, len(slots))
+	for i := range active {
+		active[i] = true
 	}
 
-	for len(clusters) > k {
+	for remaining := len(slots); remaining > k; remaining-- {
 		minDistance := math.Inf(1)
 		mergeIdx1, mergeIdx2 := -1, -1
-		for i := 0; i < len(clusters); i++ {
-			for j := i + 1; j < len(clusters); j++ {
-				d := distance(clusters[i].Center, clusters[j].Center)
-				if d < minDistance {
-					minDistance = d
+		for i := range slots {
+			if !active[i] {
+				continue
+			}
+			for j := i + 1; j < len(slots); j++ {
+				if active[j] && distances[i][j] < minDistance {
+					minDistance = distances[i][j]
 					mergeIdx1, mergeIdx2 = i, j
 				}
 			}
 		}
 
-		newCluster := Cluster{
-			Points: append(clusters[mergeIdx1].Points, clusters[mergeIdx2].Points...),
-			Center: centroid(append(clusters[mergeIdx1].Points, clusters[mergeIdx2].Points...)),
+		points := make([][]float64, 0, len(slots[mergeIdx1].Points)+len(slots[mergeIdx2].Points))
+		points = append(points, slots[mergeIdx1].Points...)
+		points = append(points, slots[mergeIdx2].Points...)
+		slots[mergeIdx1] = Cluster{
+			Points: points,
+			Center: centroid(points),
 		}
+		active[mergeIdx2] = false
 
-		// Remove the clusters being merged and add the new cluster
-		clusters = append(clusters[:mergeIdx2], clusters[mergeIdx2+1:]...)
-		clusters = append(clusters[:mergeIdx1], clusters[mergeIdx1+1:]...)
-		clusters = append(clusters, newCluster)
+		for j := range slots {
+			if active[j] && j != mergeIdx1 {
+				d := distance(slots[mergeIdx1].Center, slots[j].Center)
+				distances[mergeIdx1][j] = d
+				distances[j][mergeIdx1] = d
+			}
+		}
+	}
+
+	var clusters []Cluster
+	for i, cluster := range slots {
+		if active[i] {
+			clusters = append(clusters, cluster)
+		}
 	}
 
 	// Convert clusters to cluster assignments
@@ -1,55 +1,212 @@
 package hyperparameterTuning
 
 import(
+	"context"
+	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
 )
 
 // Model represents a machine learning model.
+// Clone returns an independent copy of the model, so that parallel searches can give each worker its own model.
 type Model interface {
 	Fit(X [][]float64, y []float64)
 	Predict(X []float64) float64
 	SetParameter(param string, value float64)
+	Clone() Model
+}
+
+// TypedModel is a model with integer or categorical hyperparameters as well as float ones.
+// SetParam receives a float64, an int or a string depending on the ParamSpec of the parameter,
+// and Clone must return a TypedModel.
+type TypedModel interface {
+	Model
+	SetParam(name string, value any)
+}
+
+// ParamSpec describes the values searched for one hyperparameter of a TypedModel.
+type ParamSpec struct {
+	Type       string    // "float", "int" or "categorical"
+	Values     []float64 // Values of a float or int parameter
+	Categories []string  // Values of a categorical parameter
+}
+
+// grid returns the float values searched for the parameter; categories are searched by index
+func (spec ParamSpec) grid() []float64 {
+	if spec.Type != "categorical" {
+		return spec.Values
+	}
+	indices := make([]float64, len(spec.Categories))
+	for i := range indices {
+		indices[i] = float64(i)
+	}
+	return indices
+}
+
+// value converts a value from the grid of the parameter to its type
+func (spec ParamSpec) value(v float64) any {
+	switch spec.Type {
+	case "int":
+		return int(v)
+	case "categorical":
+		return spec.Categories[int(v)]
+	}
+	return v
+}
+
+// typedModel adapts a TypedModel to a Model, converting the grid values passed to SetParameter
+type typedModel struct {
+	TypedModel
+	specs map[string]ParamSpec
+}
+
+// SetParameter converts the value to the type of the parameter and passes it to SetParam
+func (m *typedModel) SetParameter(param string, value float64) {
+	m.SetParam(param, m.specs[param].value(value))
+}
+
+// Clone clones the underlying model
+func (m *typedModel) Clone() Model {
+	return &typedModel{TypedModel: m.TypedModel.Clone().(TypedModel), specs: m.specs}
+}
+
+// TypedParamResult holds the score of one evaluated combination of typed parameters.
+type TypedParamResult struct {
+	Params    map[string]any
+	MeanScore float64
+	StdScore  float64
+}
+
+// TypedTuningResult represents the result of hyperparameter tuning over typed parameters.
+type TypedTuningResult struct {
+	BestParams map[string]any
+	BestScore  float64
+	Results    []TypedParamResult
+}
+
+// GridSearchTyped performs grid search over integer, categorical and float hyperparameters.
+// It runs GridSearch on the float encoding of the grid, so the other arguments behave the same.
+func GridSearchTyped(model TypedModel, paramSpecs map[string]ParamSpec, evalFunc EvaluationFunction, X [][]float64, y []float64, numFolds int, stratified bool, numWorkers int, seed int64) (*TypedTuningResult, error) {
+	paramGrid := make(map[string][]float64)
+	for param, spec := range paramSpecs {
+		paramGrid[param] = spec.grid()
+	}
+
+	result, err := GridSearch(&typedModel{TypedModel: model, specs: paramSpecs}, paramGrid, evalFunc, X, y, numFolds, stratified, numWorkers, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the parameters back to their types
+	typed := func(params map[string]float64) map[string]any {
+		converted := make(map[string]any)
+		for param, value := range params {
+			converted[param] = paramSpecs[param].value(value)
+		}
+		return converted
+	}
+	typedResult := &TypedTuningResult{
+		BestParams: typed(result.BestParams),
+		BestScore:  result.BestScore,
+		Results:    make([]TypedParamResult, len(result.Results)),
+	}
+	for i, r := range result.Results {
+		typedResult.Results[i] = TypedParamResult{Params: typed(r.Params), MeanScore: r.MeanScore, StdScore: r.StdScore}
+	}
+	return typedResult, nil
 }
 
 // EvaluationFunction is a function type for evaluating model performance.
+// Parallel searches call it from several goroutines at once.
 type EvaluationFunction func(yTrue, yPred []float64) float64
 
+// ParamResult holds the score of one evaluated parameter combination.
+type ParamResult struct {
+	Params     map[string]float64
+	MeanScore  float64            // Mean score over the validation folds
+	StdScore   float64            // Standard deviation of the score over the validation folds
+	MeanScores map[string]float64 // Mean score of every metric of GridSearchMultiMetric; MeanScore is the refit metric's
+}
+
 // HyperparameterTuningResult represents the result of hyperparameter tuning.
 type HyperparameterTuningResult struct {
 	BestParams map[string]float64
 	BestScore  float64
+	Results    []ParamResult // Every evaluated combination, in evaluation order
 }
 
 // GridSearch performs hyperparameter tuning using grid search.
-func GridSearch(model Model, paramGrid map[string][]float64, evalFunc EvaluationFunction, X [][]float64, y []float64, numFolds int) (*HyperparameterTuningResult, error) {
+// With stratified, every fold keeps the class proportions of y, treating each distinct value as a class.
+// The rows are shuffled into numFolds folds by a generator seeded with seed, so the same seed gives the same folds.
+// numFolds must be between 2 and len(X); with numFolds equal to len(X) it performs leave-one-out cross-validation,
+// so evalFunc is called with single samples.
+// Combinations are evaluated by numWorkers goroutines, each with its own clone of model (0 uses one per CPU).
+// The result does not depend on the number of workers: ties go to the combination that comes first in the grid.
+func GridSearch(model Model, paramGrid map[string][]float64, evalFunc EvaluationFunction, X [][]float64, y []float64, numFolds int, stratified bool, numWorkers int, seed int64) (*HyperparameterTuningResult, error) {
+	return GridSearchMultiMetric(model, paramGrid, map[string]EvaluationFunction{"score": evalFunc}, "score", X, y, numFolds, stratified, numWorkers, seed)
+}
+
+// GridSearchMultiMetric performs grid search scoring every combination with each of the named metrics.
+// The metric named refit selects BestParams and gives MeanScore and StdScore; the others are only recorded in MeanScores.
+// The remaining arguments behave as in GridSearch.
+func GridSearchMultiMetric(model Model, paramGrid map[string][]float64, metrics map[string]EvaluationFunction, refit string, X [][]float64, y []float64, numFolds int, stratified bool, numWorkers int, seed int64) (*HyperparameterTuningResult, error) {
+	if _, ok := metrics[refit]; !ok {
+		return nil, fmt.Errorf("refit metric %q is not one of the metrics", refit)
+	}
+
+	// Use the same folds for every combination
+	folds, err := makeFolds(y, numFolds, stratified, rand.New(rand.NewSource(seed)))
+	if err != nil {
+		return nil, err
+	}
 	bestScore := math.Inf(-1)
 	bestParams := make(map[string]float64)
 
 	// Generate all combinations of parameters
 	paramCombos := parameterCombinations(paramGrid)
 
-	// Iterate over parameter combinations
-	for _, params := range paramCombos {
-		// Set model parameters
-		for param, value := range params {
-			model.SetParameter(param, value)
-		}
+	// Score the parameter combinations in parallel
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	foldScores := make([]map[string][]float64, len(paramCombos))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(model Model) {
+			defer wg.Done()
+			for c := range jobs {
+				// Set model parameters
+				for param, value := range paramCombos[c] {
+					model.SetParameter(param, value)
+				}
 
-		// Perform cross-validation
-		scores := make([]float64, numFolds)
-		for i := 0; i < numFolds; i++ {
-			XTrain, yTrain, XValid, yValid := splitData(X, y, 1.0/float64(numFolds))
-			model.Fit(XTrain, yTrain)
-			yPred := make([]float64, len(XValid))
-			for j, sample := range XValid {
-				yPred[j] = model.Predict(sample)
+				// Perform cross-validation
+				foldScores[c] = crossValidateMetrics(model, metrics, X, y, folds)
 			}
-			scores[i] = evalFunc(yValid, yPred)
-		}
+		}(model.Clone())
+	}
+	for c := range paramCombos {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
 
-		// Compute average score
-		avgScore := average(scores)
+	// Iterate over parameter combinations in grid order
+	results := make([]ParamResult, len(paramCombos))
+	for c, params := range paramCombos {
+		// Compute average scores
+		meanScores := make(map[string]float64)
+		for name, scores := range foldScores[c] {
+			meanScores[name] = average(scores)
+		}
+		avgScore := meanScores[refit]
+		results[c] = ParamResult{Params: params, MeanScore: avgScore, StdScore: standardDeviation(foldScores[c][refit]), MeanScores: meanScores}
 
 		// Update best parameters if necessary
 		if avgScore > bestScore {
@@ -63,8 +220,163 @@ func GridSearch(model Model, paramGrid map[string][]float64, evalFunc Evaluation
 	return &HyperparameterTuningResult{
 		BestParams: bestParams,
 		BestScore:  bestScore,
+		Results:    results,
 	}, nil
 }
+
+// NestedCV estimates the generalization score of tuning the model with GridSearch.
+// For each of the outerFolds folds, an inner grid search with innerFolds folds selects parameters on the other folds,
+// the model is refitted with them on those folds and scored on the held-out fold.
+// It returns the mean and standard deviation of the outer scores.
+// Both the outer and the inner folds are shuffled with seed.
+func NestedCV(model Model, paramGrid map[string][]float64, evalFunc EvaluationFunction, X [][]float64, y []float64, outerFolds, innerFolds int, seed int64) (meanScore, stdScore float64, err error) {
+	folds, err := makeFolds(y, outerFolds, false, rand.New(rand.NewSource(seed)))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	scores := make([]float64, len(folds))
+	for i, testIndices := range folds {
+		XTrain, yTrain, XTest, yTest := foldSplit(X, y, testIndices)
+
+		// Select parameters on the outer training rows only
+		result, err := GridSearch(model, paramGrid, evalFunc, XTrain, yTrain, innerFolds, false, 0, seed)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// Refit with the selected parameters and score on the outer test rows
+		for param, value := range result.BestParams {
+			model.SetParameter(param, value)
+		}
+		model.Fit(XTrain, yTrain)
+		yPred := make([]float64, len(XTest))
+		for j, sample := range XTest {
+			yPred[j] = model.Predict(sample)
+		}
+		scores[i] = evalFunc(yTest, yPred)
+	}
+	return average(scores), standardDeviation(scores), nil
+}
+
+// makeFolds validates numFolds and partitions the indices of y into that many shuffled folds
+func makeFolds(y []float64, numFolds int, stratified bool, rng *rand.Rand) ([][]int, error) {
+	switch {
+	case numFolds < 2:
+		return nil, fmt.Errorf("numFolds must be at least 2, got %d", numFolds)
+	case numFolds > len(y):
+		return nil, fmt.Errorf("numFolds %d exceeds the %d samples", numFolds, len(y))
+	case numFolds == len(y):
+		return LeaveOneOut(len(y)), nil
+	case stratified:
+		return StratifiedKFold(y, numFolds, rng), nil
+	default:
+		return KFold(len(y), numFolds, rng), nil
+	}
+}
+
+// KFold shuffles the indices 0..n-1 with rng and partitions them into k folds whose sizes differ by at most one
+func KFold(n, k int, rng *rand.Rand) [][]int {
+	if k > n {
+		k = n
+	}
+	folds := make([][]int, k)
+	for i, index := range rng.Perm(n) {
+		folds[i%k] = append(folds[i%k], index)
+	}
+	for _, fold := range folds {
+		sort.Ints(fold)
+	}
+	return folds
+}
+
+// LeaveOneOut partitions the indices 0..n-1 into n folds of a single index each
+func LeaveOneOut(n int) [][]int {
+	folds := make([][]int, n)
+	for i := range folds {
+		folds[i] = []int{i}
+	}
+	return folds
+}
+
+// StratifiedKFold partitions the indices of y into k folds that each keep the proportion of every class.
+// The indices of each class are shuffled with rng and dealt to the folds in turn, so fold sizes differ by at most one.
+func StratifiedKFold(y []float64, k int, rng *rand.Rand) [][]int {
+	if k > len(y) {
+		k = len(y)
+	}
+	byClass := make(map[float64][]int)
+	var classes []float64
+	for i, label := range y {
+		if _, ok := byClass[label]; !ok {
+			classes = append(classes, label)
+		}
+		byClass[label] = append(byClass[label], i)
+	}
+	sort.Float64s(classes)
+
+	folds := make([][]int, k)
+	next := 0
+	for _, class := range classes {
+		indices := byClass[class]
+		rng.Shuffle(len(indices), func(i, j int) { indices[i], indices[j] = indices[j], indices[i] })
+		for _, i := range indices {
+			folds[next] = append(folds[next], i)
+			next = (next + 1) % k
+		}
+	}
+	for _, fold := range folds {
+		sort.Ints(fold)
+	}
+	return folds
+}
+
+// crossValidate trains the model on the complement of each fold and returns its score on the fold
+func crossValidate(model Model, evalFunc EvaluationFunction, X [][]float64, y []float64, folds [][]int) []float64 {
+	return crossValidateMetrics(model, map[string]EvaluationFunction{"score": evalFunc}, X, y, folds)["score"]
+}
+
+// crossValidateMetrics trains the model on the complement of each fold and returns the score of every metric on each fold
+func crossValidateMetrics(model Model, metrics map[string]EvaluationFunction, X [][]float64, y []float64, folds [][]int) map[string][]float64 {
+	scores := make(map[string][]float64)
+	for name := range metrics {
+		scores[name] = make([]float64, len(folds))
+	}
+	for i, validIndices := range folds {
+		XTrain, yTrain, XValid, yValid := foldSplit(X, y, validIndices)
+		model.Fit(XTrain, yTrain)
+		yPred := make([]float64, len(XValid))
+		for j, sample := range XValid {
+			yPred[j] = model.Predict(sample)
+		}
+		for name, evalFunc := range metrics {
+			scores[name][i] = evalFunc(yValid, yPred)
+		}
+	}
+	return scores
+}
+
+// foldSplit splits the data into the rows outside the fold, for training, and the rows of the fold, for validation
+func foldSplit(X [][]float64, y []float64, validIndices []int) ([][]float64, []float64, [][]float64, []float64) {
+	inFold := make(map[int]bool)
+	for _, i := range validIndices {
+		inFold[i] = true
+	}
+
+	var XTrain, XValid [][]float64
+	var yTrain, yValid []float64
+	for i := range X {
+		if inFold[i] {
+			XValid = append(XValid, X[i])
+			yValid = append(yValid, y[i])
+		} else {
+			XTrain = append(XTrain, X[i])
+			yTrain = append(yTrain, y[i])
+		}
+	}
+	return XTrain, yTrain, XValid, yValid
+}
+
 // splitData splits the data into training and validation sets
 func splitData(X [][]float64, y []float64, splitRatio float64) ([][]float64, []float64, [][]float64, []float64) {
     // Calculate the number of samples for the training set
@@ -82,21 +394,37 @@ func splitData(X [][]float64, y []float64, splitRatio float64) ([][]float64, []f
 }
 
 // RandomizedSearch performs hyperparameter tuning using randomized search.
-func RandomizedSearch(model Model, paramGrid map[string][]float64, evalFunc EvaluationFunction, X [][]float64, y []float64, numIterations int) (*HyperparameterTuningResult, error) {
+// Each combination is trained on the first 80% of the rows and scored on the rest, without cross-validation.
+// It stops early once the best score has not improved for patience consecutive iterations (0 never stops early).
+// If ctx is cancelled it stops before the next iteration and returns the best found so far together with ctx.Err().
+// Parameters are sampled from a generator seeded with seed, so the same seed and grid give the same result,
+// and the global math/rand generator is left untouched.
+func RandomizedSearch(ctx context.Context, model Model, paramGrid map[string][]float64, evalFunc EvaluationFunction, X [][]float64, y []float64, numIterations, patience int, seed int64) (*HyperparameterTuningResult, error) {
+	rng := rand.New(rand.NewSource(seed))
 	bestScore := math.Inf(-1)
 	bestParams := make(map[string]float64)
+	results := make([]ParamResult, 0, numIterations)
+	sinceImprovement := 0
 
 	// Iterate over random parameter combinations
 	for i := 0; i < numIterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return &HyperparameterTuningResult{
+				BestParams: bestParams,
+				BestScore:  bestScore,
+				Results:    results,
+			}, err
+		}
+
 		// Generate random parameters
-		params := randomParameters(paramGrid)
+		params := randomParameters(paramGrid, rng)
 
 		// Set model parameters
 		for param, value := range params {
 			model.SetParameter(param, value)
 		}
 
-		// Perform cross-validation
+		// Score on a hold-out split of the last 20% of the rows
 		XTrain, yTrain, XValid, yValid := splitData(X, y, 0.8)
 		model.Fit(XTrain, yTrain)
 		yPred := make([]float64, len(XValid))
@@ -105,27 +433,255 @@ func RandomizedSearch(model Model, paramGrid map[string][]float64, evalFunc Eval
 		}
 		score := evalFunc(yValid, yPred)
 
+		// A single validation split has no spread
+		results = append(results, ParamResult{Params: params, MeanScore: score})
+
 		// Update best parameters if necessary
 		if score > bestScore {
 			bestScore = score
 			for param, value := range params {
 				bestParams[param] = value
 			}
+			sinceImprovement = 0
+		} else {
+			sinceImprovement++
+			if patience > 0 && sinceImprovement >= patience {
+				break
+			}
+		}
+	}
+
+	return &HyperparameterTuningResult{
+		BestParams: bestParams,
+		BestScore:  bestScore,
+		Results:    results,
+	}, nil
+}
+
+const (
+	bayesianInitialPoints = 3    // Random combinations evaluated before the Gaussian process guides the search
+	gpLengthScale         = 0.25 // Length scale of the RBF kernel over parameters scaled to [0, 1]
+	gpNoise               = 1e-6 // Variance added to the kernel diagonal for numerical stability
+)
+
+// BayesianSearch performs hyperparameter tuning using Bayesian optimization over the parameter grid.
+// After a few random combinations, it fits a Gaussian process to the cross-validation scores seen so far
+// and evaluates the combination with the largest expected improvement, for numIterations evaluations in all.
+// The initial combinations are drawn from a generator seeded with seed, so the same seed and grid give the same
+// result, and the global math/rand generator is left untouched.
+func BayesianSearch(model Model, paramGrid map[string][]float64, evalFunc EvaluationFunction, X [][]float64, y []float64, numFolds, numIterations int, seed int64) (*HyperparameterTuningResult, error) {
+	rng := rand.New(rand.NewSource(seed))
+	paramCombos := parameterCombinations(paramGrid)
+	if numIterations > len(paramCombos) {
+		numIterations = len(paramCombos)
+	}
+	points := scaleCombinations(paramCombos, paramGrid)
+	folds, err := makeFolds(y, numFolds, false, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	bestScore := math.Inf(-1)
+	bestParams := make(map[string]float64)
+	results := make([]ParamResult, 0, numIterations)
+	evaluated := make([]bool, len(paramCombos))
+	var evaluatedPoints [][]float64
+	var scores []float64
+
+	for i := 0; i < numIterations; i++ {
+		// Choose the next combination
+		var next int
+		if i < bayesianInitialPoints {
+			next = rng.Intn(len(paramCombos))
+			for evaluated[next] {
+				next = rng.Intn(len(paramCombos))
+			}
+		} else {
+			next = nextByExpectedImprovement(points, evaluated, evaluatedPoints, scores, bestScore)
+		}
+		evaluated[next] = true
+		params := paramCombos[next]
+
+		// Set model parameters
+		for param, value := range params {
+			model.SetParameter(param, value)
+		}
+
+		// Perform cross-validation
+		foldScores := crossValidate(model, evalFunc, X, y, folds)
+		avgScore := average(foldScores)
+		results = append(results, ParamResult{Params: params, MeanScore: avgScore, StdScore: standardDeviation(foldScores)})
+		evaluatedPoints = append(evaluatedPoints, points[next])
+		scores = append(scores, avgScore)
+
+		// Update best parameters if necessary
+		if avgScore > bestScore {
+			bestScore = avgScore
+			for param, value := range params {
+				bestParams[param] = value
+			}
 		}
 	}
 
 	return &HyperparameterTuningResult{
 		BestParams: bestParams,
 		BestScore:  bestScore,
+		Results:    results,
 	}, nil
 }
 
-// parameterCombinations generates all combinations of parameters from the parameter grid.
+// scaleCombinations maps every combination to a point with one coordinate per parameter, in sorted parameter order,
+// scaled to [0, 1] by the smallest and largest value of the parameter in the grid
+func scaleCombinations(paramCombos []map[string]float64, paramGrid map[string][]float64) [][]float64 {
+	var keys []string
+	for key := range paramGrid {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	points := make([][]float64, len(paramCombos))
+	for c, params := range paramCombos {
+		points[c] = make([]float64, len(keys))
+		for k, key := range keys {
+			low, high := math.Inf(1), math.Inf(-1)
+			for _, value := range paramGrid[key] {
+				low = math.Min(low, value)
+				high = math.Max(high, value)
+			}
+			if high > low {
+				points[c][k] = (params[key] - low) / (high - low)
+			}
+		}
+	}
+	return points
+}
+
+// nextByExpectedImprovement fits a Gaussian process to the standardized scores of the evaluated points
+// and returns the index of the unevaluated point with the largest expected improvement over bestScore
+func nextByExpectedImprovement(points [][]float64, evaluated []bool, evaluatedPoints [][]float64, scores []float64, bestScore float64) int {
+	mean := average(scores)
+	std := standardDeviation(scores)
+	if std == 0 {
+		std = 1
+	}
+	targets := make([]float64, len(scores))
+	for i, score := range scores {
+		targets[i] = (score - mean) / std
+	}
+	best := (bestScore - mean) / std
+
+	// Kernel matrix of the evaluated points and its Cholesky factor
+	n := len(evaluatedPoints)
+	kernel := make([][]float64, n)
+	for i := range kernel {
+		kernel[i] = make([]float64, n)
+		for j := range kernel[i] {
+			kernel[i][j] = rbf(evaluatedPoints[i], evaluatedPoints[j])
+		}
+		kernel[i][i] += gpNoise
+	}
+	lower := cholesky(kernel)
+	alpha := solveCholesky(lower, targets)
+
+	next := -1
+	bestImprovement := math.Inf(-1)
+	for c, point := range points {
+		if evaluated[c] {
+			continue
+		}
+
+		// Posterior mean and standard deviation of the score at the point
+		k := make([]float64, n)
+		for i, evaluatedPoint := range evaluatedPoints {
+			k[i] = rbf(point, evaluatedPoint)
+		}
+		mu := 0.0
+		for i := range k {
+			mu += k[i] * alpha[i]
+		}
+		v := solveCholesky(lower, k)
+		variance := rbf(point, point)
+		for i := range k {
+			variance -= k[i] * v[i]
+		}
+		sigma := math.Sqrt(math.Max(variance, 0))
+
+		improvement := math.Max(mu-best, 0)
+		if sigma > 0 {
+			z := (mu - best) / sigma
+			cdf := 0.5 * (1 + math.Erf(z/math.Sqrt2))
+			pdf := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+			improvement = (mu-best)*cdf + sigma*pdf
+		}
+		if improvement > bestImprovement {
+			bestImprovement = improvement
+			next = c
+		}
+	}
+	return next
+}
+
+// rbf computes the radial basis function kernel between two points
+func rbf(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		diff := a[i] - b[i]
+		sum += diff * diff
+	}
+	return math.Exp(-sum / (2 * gpLengthScale * gpLengthScale))
+}
+
+// cholesky computes the lower triangular factor L of a symmetric positive definite matrix A = L L^T
+func cholesky(matrix [][]float64) [][]float64 {
+	n := len(matrix)
+	lower := make([][]float64, n)
+	for i := range lower {
+		lower[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			sum := matrix[i][j]
+			for k := 0; k < j; k++ {
+				sum -= lower[i][k] * lower[j][k]
+			}
+			if i == j {
+				lower[i][i] = math.Sqrt(math.Max(sum, gpNoise))
+			} else {
+				lower[i][j] = sum / lower[j][j]
+			}
+		}
+	}
+	return lower
+}
+
+// solveCholesky solves L L^T x = b by forward and back substitution
+func solveCholesky(lower [][]float64, b []float64) []float64 {
+	n := len(b)
+	z := make([]float64, n)
+	for i := 0; i < n; i++ {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= lower[i][k] * z[k]
+		}
+		z[i] = sum / lower[i][i]
+	}
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := z[i]
+		for k := i + 1; k < n; k++ {
+			sum -= lower[k][i] * x[k]
+		}
+		x[i] = sum / lower[i][i]
+	}
+	return x
+}
+
+// parameterCombinations generates all combinations of parameters from the parameter grid,
+// in an order that depends only on the grid.
 func parameterCombinations(paramGrid map[string][]float64) []map[string]float64 {
 	var keys []string
 	for key := range paramGrid {
 		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 	return parameterCombinationsHelper(keys, paramGrid, make(map[string]float64), nil)
 }
 
@@ -149,11 +705,19 @@ func parameterCombinationsHelper(keys []string, paramGrid map[string][]float64,
 	return result
 }
 
-// randomParameters generates random parameters from the parameter grid.
-func randomParameters(paramGrid map[string][]float64) map[string]float64 {
+// randomParameters generates random parameters from the parameter grid,
+// drawing from rng in sorted parameter order so that the draws do not depend on map iteration order.
+func randomParameters(paramGrid map[string][]float64, rng *rand.Rand) map[string]float64 {
+	var keys []string
+	for key := range paramGrid {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	params := make(map[string]float64)
-	for param, values := range paramGrid {
-		params[param] = values[rand.Intn(len(values))]
+	for _, param := range keys {
+		values := paramGrid[param]
+		params[param] = values[rng.Intn(len(values))]
 	}
 	return params
 }
@@ -167,6 +731,16 @@ func average(arr []float64) float64 {
 	return sum / float64(len(arr))
 }
 
+// standardDeviation calculates the population standard deviation of a slice of float64 values.
+func standardDeviation(arr []float64) float64 {
+	mean := average(arr)
+	sum := 0.0
+	for _, value := range arr {
+		sum += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(sum / float64(len(arr)))
+}
+
 // SplitData splits the data into training and validation sets.
 func SplitData(X [][]float64, y []float64, splitRatio float64) ([][]float64, []float64, [][]float64, []float64) {
 	numTrain := int(float64(len(X)) * splitRatio)
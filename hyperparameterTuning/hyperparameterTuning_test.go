@@ -0,0 +1,404 @@
+package hyperparameterTuning
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// surfaceModel ignores its data and predicts a smooth function of its parameters a and b,
+// so that scoring it with meanPrediction maps the score surface directly
+type surfaceModel struct {
+	a, b float64
+}
+
+func (m *surfaceModel) Fit(X [][]float64, y []float64) {}
+
+func (m *surfaceModel) Predict(X []float64) float64 {
+	return -((m.a-3.3)*(m.a-3.3) + (m.b-6.1)*(m.b-6.1)/4)
+}
+
+func (m *surfaceModel) SetParameter(param string, value float64) {
+	if param == "a" {
+		m.a = value
+	} else {
+		m.b = value
+	}
+}
+
+func (m *surfaceModel) Clone() Model {
+	clone := *m
+	return &clone
+}
+
+// meanPrediction scores a model by its mean prediction
+func meanPrediction(yTrue, yPred []float64) float64 {
+	return average(yPred)
+}
+
+// surfaceData returns a small dataset for models that ignore their data
+func surfaceData() ([][]float64, []float64) {
+	X := [][]float64{{0}, {1}, {2}, {3}, {4}, {5}}
+	y := []float64{0, 0, 0, 0, 0, 0}
+	return X, y
+}
+
+// surfaceGrid returns a 41 x 41 grid over [0, 10] for both parameters
+func surfaceGrid() map[string][]float64 {
+	var values []float64
+	for i := 0; i <= 40; i++ {
+		values = append(values, float64(i)/4)
+	}
+	return map[string][]float64{"a": values, "b": values}
+}
+
+func TestBayesianSearchBeatsRandomSearch(t *testing.T) {
+	X, y := surfaceData()
+	const budget = 25
+	const runs = 10
+
+	bayesianTotal, randomTotal := 0.0, 0.0
+	for seed := int64(0); seed < runs; seed++ {
+		bayesian, err := BayesianSearch(&surfaceModel{}, surfaceGrid(), meanPrediction, X, y, 3, budget, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		random, err := RandomizedSearch(context.Background(), &surfaceModel{}, surfaceGrid(), meanPrediction, X, y, budget, 0, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bayesian.Results) != budget {
+			t.Fatalf("BayesianSearch evaluated %d combinations, want %d", len(bayesian.Results), budget)
+		}
+		bayesianTotal += bayesian.BestScore
+		randomTotal += random.BestScore
+	}
+
+	// The optimum of the grid scores -0.0025
+	if bayesianTotal/runs < -0.1 {
+		t.Errorf("BayesianSearch mean best score %v, want near the optimum", bayesianTotal/runs)
+	}
+	if bayesianTotal <= randomTotal {
+		t.Errorf("BayesianSearch mean best score %v not better than RandomizedSearch %v", bayesianTotal/runs, randomTotal/runs)
+	}
+}
+
+func TestBayesianSearchIsReproducible(t *testing.T) {
+	X, y := surfaceData()
+	first, _ := BayesianSearch(&surfaceModel{}, surfaceGrid(), meanPrediction, X, y, 3, 10, 7)
+	second, _ := BayesianSearch(&surfaceModel{}, surfaceGrid(), meanPrediction, X, y, 3, 10, 7)
+	for i := range first.Results {
+		if first.Results[i].Params["a"] != second.Results[i].Params["a"] || first.Results[i].Params["b"] != second.Results[i].Params["b"] {
+			t.Fatalf("evaluation %d differs between runs with the same seed: %v and %v", i, first.Results[i].Params, second.Results[i].Params)
+		}
+	}
+}
+
+func TestFoldsValidateEverySampleOnce(t *testing.T) {
+	y := []float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	for _, stratified := range []bool{false, true} {
+		folds, err := makeFolds(y, 5, stratified, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(folds) != 5 {
+			t.Fatalf("got %d folds, want 5", len(folds))
+		}
+		seen := make([]int, len(y))
+		for _, fold := range folds {
+			for _, i := range fold {
+				seen[i]++
+			}
+		}
+		for i, count := range seen {
+			if count != 1 {
+				t.Errorf("stratified=%v: sample %d validated %d times", stratified, i, count)
+			}
+		}
+	}
+
+	// Sorted labels must not end up in contiguous single-class folds
+	folds := KFold(len(y), 5, rand.New(rand.NewSource(1)))
+	for _, fold := range folds {
+		if y[fold[0]] == y[fold[len(fold)-1]] && fold[len(fold)-1]-fold[0] == len(fold)-1 {
+			t.Errorf("fold %v is contiguous", fold)
+		}
+	}
+}
+
+func TestGridSearchRejectsTooManyFolds(t *testing.T) {
+	X, y := surfaceData()
+	if _, err := GridSearch(&surfaceModel{}, map[string][]float64{"a": {1, 2}}, meanPrediction, X, y, len(X)+1, false, 1, 0); err == nil {
+		t.Error("expected an error for more folds than samples")
+	}
+	if _, err := GridSearch(&surfaceModel{}, map[string][]float64{"a": {1, 2}}, meanPrediction, X, y, 1, false, 1, 0); err == nil {
+		t.Error("expected an error for a single fold")
+	}
+}
+
+func TestStratifiedKFoldKeepsBothClasses(t *testing.T) {
+	y := make([]float64, 100)
+	for i := 90; i < 100; i++ {
+		y[i] = 1
+	}
+	folds := StratifiedKFold(y, 5, rand.New(rand.NewSource(3)))
+	for f, fold := range folds {
+		minority := 0
+		for _, i := range fold {
+			if y[i] == 1 {
+				minority++
+			}
+		}
+		if minority != 2 || len(fold) != 20 {
+			t.Errorf("fold %d has %d of %d samples in the minority class, want 2 of 20", f, minority, len(fold))
+		}
+	}
+}
+
+func TestGridSearchReportsEveryCombination(t *testing.T) {
+	X, y := surfaceData()
+	grid := map[string][]float64{"a": {1, 3, 5}, "b": {2, 6}}
+	result, err := GridSearch(&surfaceModel{}, grid, meanPrediction, X, y, 3, false, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 6 {
+		t.Fatalf("got %d result rows, want 6", len(result.Results))
+	}
+	best := math.Inf(-1)
+	for _, r := range result.Results {
+		best = math.Max(best, r.MeanScore)
+		if r.StdScore > 1e-9 {
+			t.Errorf("params %v: std %v, want 0 for a model that ignores its data", r.Params, r.StdScore)
+		}
+	}
+	if best != result.BestScore || result.BestParams["a"] != 3 || result.BestParams["b"] != 6 {
+		t.Errorf("best %v with score %v, want a=3 b=6 with the highest row score %v", result.BestParams, result.BestScore, best)
+	}
+}
+
+// treeSettingsModel scores an integer depth and a categorical criterion, best at depth 4 with "entropy"
+type treeSettingsModel struct {
+	depth     int
+	criterion string
+}
+
+func (m *treeSettingsModel) Fit(X [][]float64, y []float64) {}
+
+func (m *treeSettingsModel) Predict(X []float64) float64 {
+	score := -math.Abs(float64(m.depth - 4))
+	if m.criterion == "entropy" {
+		score++
+	}
+	return score
+}
+
+func (m *treeSettingsModel) SetParameter(param string, value float64) {
+	m.SetParam(param, int(value))
+}
+
+func (m *treeSettingsModel) SetParam(name string, value any) {
+	switch name {
+	case "depth":
+		m.depth = value.(int)
+	case "criterion":
+		m.criterion = value.(string)
+	}
+}
+
+func (m *treeSettingsModel) Clone() Model {
+	clone := *m
+	return &clone
+}
+
+func TestGridSearchTypedParameters(t *testing.T) {
+	X, y := surfaceData()
+	specs := map[string]ParamSpec{
+		"depth":     {Type: "int", Values: []float64{2, 3, 4, 5, 6}},
+		"criterion": {Type: "categorical", Categories: []string{"gini", "entropy"}},
+	}
+	result, err := GridSearchTyped(&treeSettingsModel{}, specs, meanPrediction, X, y, 3, false, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 10 {
+		t.Errorf("got %d result rows, want 10", len(result.Results))
+	}
+	if depth, ok := result.BestParams["depth"].(int); !ok || depth != 4 {
+		t.Errorf("best depth %#v, want int 4", result.BestParams["depth"])
+	}
+	if criterion, ok := result.BestParams["criterion"].(string); !ok || criterion != "entropy" {
+		t.Errorf("best criterion %#v, want \"entropy\"", result.BestParams["criterion"])
+	}
+}
+
+func TestGridSearchMultiMetricSelectsByRefit(t *testing.T) {
+	X, y := surfaceData()
+	grid := map[string][]float64{"a": {0, 3, 10}}
+	metrics := map[string]EvaluationFunction{
+		"closeness": meanPrediction,
+		"distance": func(yTrue, yPred []float64) float64 {
+			return -meanPrediction(yTrue, yPred)
+		},
+	}
+
+	byCloseness, err := GridSearchMultiMetric(&surfaceModel{}, grid, metrics, "closeness", X, y, 3, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byDistance, err := GridSearchMultiMetric(&surfaceModel{}, grid, metrics, "distance", X, y, 3, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byCloseness.BestParams["a"] != 3 || byDistance.BestParams["a"] != 10 {
+		t.Errorf("best a = %v by closeness and %v by distance, want 3 and 10", byCloseness.BestParams["a"], byDistance.BestParams["a"])
+	}
+	for _, r := range byDistance.Results {
+		if len(r.MeanScores) != 2 || r.MeanScores["distance"] != r.MeanScore || r.MeanScores["closeness"] != -r.MeanScore {
+			t.Errorf("params %v: mean scores %v do not record both metrics", r.Params, r.MeanScores)
+		}
+	}
+
+	if _, err := GridSearchMultiMetric(&surfaceModel{}, grid, metrics, "auc", X, y, 3, false, 1, 0); err == nil {
+		t.Error("expected an error for an unknown refit metric")
+	}
+}
+
+func TestGridSearchLeaveOneOut(t *testing.T) {
+	X, y := surfaceData()
+	calls := 0
+	countingMetric := func(yTrue, yPred []float64) float64 {
+		if len(yTrue) != 1 || len(yPred) != 1 {
+			t.Errorf("validation fold of %d samples, want 1", len(yTrue))
+		}
+		calls++
+		return meanPrediction(yTrue, yPred)
+	}
+
+	result, err := GridSearch(&surfaceModel{}, map[string][]float64{"a": {3}}, countingMetric, X, y, len(X), false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(X) {
+		t.Errorf("evaluated %d folds, want %d", calls, len(X))
+	}
+	if math.IsNaN(result.BestScore) {
+		t.Error("leave-one-out score is NaN")
+	}
+}
+
+// slopeModel predicts w times the first feature
+type slopeModel struct {
+	w float64
+}
+
+func (m *slopeModel) Fit(X [][]float64, y []float64) {}
+
+func (m *slopeModel) Predict(X []float64) float64 {
+	return m.w * X[0]
+}
+
+func (m *slopeModel) SetParameter(param string, value float64) {
+	m.w = value
+}
+
+func (m *slopeModel) Clone() Model {
+	clone := *m
+	return &clone
+}
+
+// negativeMSE scores predictions by their negated mean squared error
+func negativeMSE(yTrue, yPred []float64) float64 {
+	sum := 0.0
+	for i := range yTrue {
+		sum += (yTrue[i] - yPred[i]) * (yTrue[i] - yPred[i])
+	}
+	return -sum / float64(len(yTrue))
+}
+
+func TestNestedCV(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	var X [][]float64
+	var y []float64
+	for i := 0; i < 40; i++ {
+		x := rng.Float64() * 10
+		X = append(X, []float64{x})
+		y = append(y, 2*x+rng.NormFloat64()*0.1)
+	}
+
+	mean, std, err := NestedCV(&slopeModel{}, map[string][]float64{"w": {0, 1, 2, 3}}, negativeMSE, X, y, 4, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Selecting w = 2 in every outer fold leaves only the noise, with variance 0.01
+	if mean > 0 || mean < -0.05 {
+		t.Errorf("mean score %v, want the negated noise variance of about -0.01", mean)
+	}
+	if std < 0 || std > 0.05 {
+		t.Errorf("std %v, want a small non-negative spread", std)
+	}
+
+	if _, _, err := NestedCV(&slopeModel{}, map[string][]float64{"w": {2}}, negativeMSE, X, y, 1, 3, 0); err == nil {
+		t.Error("expected an error for a single outer fold")
+	}
+}
+
+func TestRandomizedSearchIsReproducible(t *testing.T) {
+	X, y := surfaceData()
+	first, err := RandomizedSearch(context.Background(), &surfaceModel{}, surfaceGrid(), meanPrediction, X, y, 15, 0, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RandomizedSearch(context.Background(), &surfaceModel{}, surfaceGrid(), meanPrediction, X, y, 15, 0, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("runs with the same seed differ: %+v and %+v", first, second)
+	}
+}
+
+func TestGridSearchIsIndependentOfWorkers(t *testing.T) {
+	rng := rand.New(rand.NewSource(8))
+	var X [][]float64
+	var y []float64
+	for i := 0; i < 30; i++ {
+		x := rng.Float64() * 10
+		X = append(X, []float64{x})
+		y = append(y, 2*x+rng.NormFloat64())
+	}
+	metrics := map[string]EvaluationFunction{
+		"mse": negativeMSE,
+		"mean": meanPrediction,
+	}
+	grid := map[string][]float64{"w": {0, 1, 1.5, 2, 2.5, 3}}
+
+	search := func(numWorkers int) *HyperparameterTuningResult {
+		result, err := GridSearchMultiMetric(&slopeModel{}, grid, metrics, "mse", X, y, 5, false, numWorkers, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	sequential := search(1)
+	for run := 0; run < 5; run++ {
+		if parallel := search(4); !reflect.DeepEqual(sequential, parallel) {
+			t.Fatalf("results with 4 workers differ from 1 worker: %+v and %+v", parallel, sequential)
+		}
+	}
+
+	// Tied combinations resolve to the first in grid order whatever the number of workers
+	tied := map[string]ParamSpec{"depth": {Type: "int", Values: []float64{3, 5}}, "criterion": {Type: "categorical", Categories: []string{"gini"}}}
+	Xs, ys := surfaceData()
+	for run := 0; run < 5; run++ {
+		result, err := GridSearchTyped(&treeSettingsModel{}, tied, meanPrediction, Xs, ys, 3, false, 4, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.BestParams["depth"] != 3 {
+			t.Fatalf("tie resolved to depth %v, want the first value 3", result.BestParams["depth"])
+		}
+	}
+}
@@ -17,10 +17,19 @@ type Cluster struct {
 	Points   []Point
 }
 
-// KMeans performs k-means clustering on a given dataset
-func KMeans(data []Point, k int, maxIterations int) ([]Cluster, error) {
+// KMeans performs k-means clustering on a given dataset.
+// It also returns the cluster index of every input point, in input order, the number of iterations run,
+// and whether the centroids stopped moving before maxIterations was reached.
+// The returned labels and cluster points always match the returned centroids, even when maxIterations is reached.
+func KMeans(data []Point, k int, maxIterations int) ([]Cluster, []int, int, bool, error) {
+	if k < 1 {
+		return nil, nil, 0, false, fmt.Errorf("k must be at least 1, got %d", k)
+	}
+	if maxIterations < 1 {
+		return nil, nil, 0, false, fmt.Errorf("maxIterations must be at least 1, got %d", maxIterations)
+	}
 	if len(data) < k {
-		return nil, fmt.Errorf("not enough data points for %d clusters", k)
+		return nil, nil, 0, false, fmt.Errorf("not enough data points for %d clusters", k)
 	}
 
 	// Initialize random centroids
@@ -32,12 +41,21 @@ func KMeans(data []Point, k int, maxIterations int) ([]Cluster, error) {
 		clusters[i].Centroid = centroids[i]
 	}
 
+	labels := make([]int, len(data))
+	for i := range labels {
+		labels[i] = -1
+	}
+
 	// Run k-means iterations
-	for iteration := 0; iteration < maxIterations; iteration++ {
-		// Assign data points to clusters
-		for _, point := range data {
-			closestClusterIndex := getClosestClusterIndex(point, clusters)
-			clusters[closestClusterIndex].Points = append(clusters[closestClusterIndex].Points, point)
+	iterations := 0
+	converged := false
+	for iterations < maxIterations {
+		iterations++
+
+		// Stop early once no point changes cluster, since the centroids can no longer move
+		if !assignPoints(data, clusters, labels) {
+			converged = true
+			break
 		}
 
 		// Update centroids of clusters
@@ -46,20 +64,148 @@ func KMeans(data []Point, k int, maxIterations int) ([]Cluster, error) {
 				clusters[i].Centroid = calculateCentroid(clusters[i].Points)
 			}
 		}
+	}
 
-		// Clear points from clusters for the next iteration
-		for i := range clusters {
-			clusters[i].Points = nil
+	// The last iteration moved the centroids, so assign the points to them once more
+	if !converged {
+		assignPoints(data, clusters, labels)
+	}
+
+	return clusters, labels, iterations, converged, nil
+}
+
+// assignPoints assigns every point to its closest cluster, recording the cluster index in labels,
+// and reports whether any label changed
+func assignPoints(data []Point, clusters []Cluster, labels []int) bool {
+	// Clear points from clusters before reassigning them
+	for i := range clusters {
+		clusters[i].Points = nil
+	}
+
+	changed := false
+	for i, point := range data {
+		closestClusterIndex := getClosestClusterIndex(point, clusters)
+		clusters[closestClusterIndex].Points = append(clusters[closestClusterIndex].Points, point)
+		if labels[i] != closestClusterIndex {
+			labels[i] = closestClusterIndex
+			changed = true
+		}
+	}
+	return changed
+}
+
+// KMeansMatrix is KMeans for the rows of a matrix, such as the output of PCA.Transform, and returns the same values
+func KMeansMatrix(data [][]float64, k int, maxIterations int) ([]Cluster, []int, int, bool, error) {
+	return KMeans(MatrixToPoints(data), k, maxIterations)
+}
+
+// PointsToMatrix returns the values of the points as the rows of a matrix
+func PointsToMatrix(points []Point) [][]float64 {
+	matrix := make([][]float64, len(points))
+	for i, point := range points {
+		matrix[i] = point.Values
+	}
+	return matrix
+}
+
+// MatrixToPoints returns the rows of a matrix as points
+func MatrixToPoints(matrix [][]float64) []Point {
+	points := make([]Point, len(matrix))
+	for i, row := range matrix {
+		points[i] = Point{Values: row}
+	}
+	return points
+}
+
+// Silhouette returns the mean silhouette coefficient of a clustering, where labels holds the cluster index of each point.
+// Values close to 1 indicate compact, well separated clusters. Points in singleton clusters score 0.
+// It returns an error for empty data, a label count that differs from the data, or a negative label.
+func Silhouette(data []Point, labels []int) (float64, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("cannot compute the silhouette of empty data")
+	}
+	if len(labels) != len(data) {
+		return 0, fmt.Errorf("got %d labels for %d points", len(labels), len(data))
+	}
+	numClusters := 0
+	for _, label := range labels {
+		if label < 0 {
+			return 0, fmt.Errorf("negative cluster label %d", label)
+		}
+		if label+1 > numClusters {
+			numClusters = label + 1
 		}
 	}
+	clusterSizes := make([]int, numClusters)
+	for _, label := range labels {
+		clusterSizes[label]++
+	}
+
+	total := 0.0
+	for i, point := range data {
+		if clusterSizes[labels[i]] <= 1 {
+			continue
+		}
+
+		// Mean distance from the point to every cluster
+		distanceSums := make([]float64, numClusters)
+		for j, other := range data {
+			if i != j {
+				distanceSums[labels[j]] += euclideanDistance(point, other)
+			}
+		}
 
-	return clusters, nil
+		a := distanceSums[labels[i]] / float64(clusterSizes[labels[i]]-1)
+		b := math.Inf(1)
+		for c := range distanceSums {
+			if c != labels[i] && clusterSizes[c] > 0 {
+				b = math.Min(b, distanceSums[c]/float64(clusterSizes[c]))
+			}
+		}
+		if math.IsInf(b, 1) {
+			continue
+		}
+		total += (b - a) / math.Max(a, b)
+	}
+	return total / float64(len(data)), nil
 }
 
-// getRandomCentroids returns random centroids from the given data
+// BestK runs KMeans for every candidate k and returns the one with the highest mean silhouette,
+// together with the silhouette of each candidate. It returns an error for an empty kRange.
+func BestK(data []Point, kRange []int, maxIter int) (int, map[int]float64, error) {
+	if len(kRange) == 0 {
+		return 0, nil, fmt.Errorf("kRange has no candidates")
+	}
+	bestK := 0
+	bestScore := math.Inf(-1)
+	scores := make(map[int]float64)
+
+	for _, k := range kRange {
+		_, labels, _, _, err := KMeans(data, k, maxIter)
+		if err != nil {
+			return 0, nil, err
+		}
+		score, err := Silhouette(data, labels)
+		if err != nil {
+			return 0, nil, err
+		}
+		scores[k] = score
+		if scores[k] > bestScore {
+			bestScore = scores[k]
+			bestK = k
+		}
+	}
+
+	return bestK, scores, nil
+}
+
+// getRandomCentroids returns random centroids from the given data without reordering it
 func getRandomCentroids(data []Point, k int) []Point {
-	rand.Shuffle(len(data), func(i, j int) { data[i], data[j] = data[j], data[i] })
-	return data[:k]
+	centroids := make([]Point, k)
+	for i, index := range rand.Perm(len(data))[:k] {
+		centroids[i] = data[index]
+	}
+	return centroids
 }
 
 // getClosestClusterIndex returns the index of the closest cluster to a given point
@@ -131,11 +277,12 @@ func main() {
 	k := 2       // Number of clusters
 	maxIter := 10 // Maximum iterations for k-means
 
-	clusters, err := KMeans(data, k, maxIter)
+	clusters, labels, iterations, converged, err := KMeans(data, k, maxIter)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
+	fmt.Printf("Ran %d iterations, converged: %v\n", iterations, converged)
 
 	// Print the clusters and their centroids
 	for i, cluster := range clusters {
@@ -143,4 +290,13 @@ func main() {
 		fmt.Println("Centroid:", cluster.Centroid)
 		fmt.Println("Points:", cluster.Points)
 	}
+	fmt.Println("Labels:", labels)
+
+	// Choose the number of clusters by silhouette
+	bestK, scores, err := BestK(data, []int{2, 3, 4}, maxIter)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Best k:", bestK, "Silhouette scores:", scores)
 }
@@ -0,0 +1,115 @@
+package kmeans
+
+import(
+	"math/rand"
+	"testing"
+)
+
+func TestKMeansLabelsMatchCentroidsAtIterationCap(t *testing.T) {
+	var data []Point
+	for i := 0; i < 20; i++ {
+		data = append(data, Point{Values: []float64{float64(i % 5), float64(i / 5 * 3)}})
+	}
+
+	if _, _, _, _, err := KMeans(data, 3, 0); err == nil {
+		t.Error("expected an error for maxIterations = 0")
+	}
+
+	for run := 0; run < 20; run++ {
+		clusters, labels, iterations, converged, err := KMeans(data, 3, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if iterations != 1 || converged {
+			t.Fatalf("iterations = %d, converged = %v; want 1 unconverged iteration", iterations, converged)
+		}
+		total := 0
+		for _, cluster := range clusters {
+			total += len(cluster.Points)
+		}
+		if total != len(data) {
+			t.Fatalf("clusters hold %d points, want %d", total, len(data))
+		}
+		for i, point := range data {
+			if labels[i] != getClosestClusterIndex(point, clusters) {
+				t.Fatalf("point %d has label %d but is closest to centroid %d", i, labels[i], getClosestClusterIndex(point, clusters))
+			}
+		}
+		// Silhouette must accept the labels of a capped run
+		if _, err := Silhouette(data, labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatrixConvertersRoundTrip(t *testing.T) {
+	matrix := [][]float64{{1, 2}, {3, 4}, {5, 6}}
+	points := MatrixToPoints(matrix)
+	if len(points) != len(matrix) {
+		t.Fatalf("got %d points, want %d", len(points), len(matrix))
+	}
+	back := PointsToMatrix(points)
+	for i := range matrix {
+		for j := range matrix[i] {
+			if points[i].Values[j] != matrix[i][j] || back[i][j] != matrix[i][j] {
+				t.Fatalf("row %d: point %v and matrix row %v, want %v", i, points[i].Values, back[i], matrix[i])
+			}
+		}
+	}
+
+	// KMeansMatrix reports the same diagnostics as KMeans
+	clusters, labels, iterations, converged, err := KMeansMatrix([][]float64{{0, 0}, {0, 1}, {10, 10}, {10, 11}}, 2, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || len(labels) != 4 || iterations < 1 || !converged {
+		t.Errorf("got %d clusters, %d labels, %d iterations, converged %v", len(clusters), len(labels), iterations, converged)
+	}
+	if labels[0] != labels[1] || labels[2] != labels[3] || labels[0] == labels[2] {
+		t.Errorf("labels %v do not separate the two pairs", labels)
+	}
+}
+
+func TestBestKFindsSeparatedBlobs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var data []Point
+	var truth []int
+	for i := 0; i < 40; i++ {
+		blob := i % 2
+		center := float64(blob) * 20
+		data = append(data, Point{Values: []float64{center + rng.NormFloat64()*0.3, center + rng.NormFloat64()*0.3}})
+		truth = append(truth, blob)
+	}
+
+	score, err := Silhouette(data, truth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score < 0.9 {
+		t.Errorf("silhouette of the true blobs = %v, want close to 1", score)
+	}
+
+	bestK, scores, err := BestK(data, []int{2, 3, 4}, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bestK != 2 {
+		t.Errorf("BestK = %d with scores %v, want 2", bestK, scores)
+	}
+	if len(scores) != 3 {
+		t.Errorf("got scores for %d candidates, want 3", len(scores))
+	}
+}
+
+func TestSilhouetteAndBestKRejectEmptyInput(t *testing.T) {
+	if _, err := Silhouette(nil, nil); err == nil {
+		t.Error("expected an error for empty data")
+	}
+	if _, err := Silhouette([]Point{{Values: []float64{0}}}, []int{0, 1}); err == nil {
+		t.Error("expected an error for mismatched labels")
+	}
+	data := []Point{{Values: []float64{0}}, {Values: []float64{1}}}
+	if _, _, err := BestK(data, nil, 10); err == nil {
+		t.Error("expected an error for an empty kRange")
+	}
+}
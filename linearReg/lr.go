@@ -7,12 +7,15 @@ import(
 	"math"
 	"os"
 	"strconv"
+
+	"ml/metrics"
 )
 
 // LinearRegression performs linear regression to find the best-fit line.
 type LinearRegression struct {
 	theta    []float64 // Parameters (theta0, theta1, ..., thetaN)
 	features int       // Number of input features
+	FeatureNames []string // Optional names of the input columns
 }
 
 // Fit trains the linear regression model using the provided input and output data.
@@ -64,6 +67,20 @@ func (lr *LinearRegression) Predict(x []float64) float64 {
 	return prediction
 }
 
+// Coefficients returns the trained weight of each feature, excluding the intercept theta0
+// It returns nil before the model is fitted.
+func (lr *LinearRegression) Coefficients() []float64 {
+	if len(lr.theta) == 0 {
+		return nil
+	}
+	return lr.theta[1:]
+}
+
+// CoefficientsNamed returns the trained weight of each feature keyed by its name
+func (lr *LinearRegression) CoefficientsNamed() map[string]float64 {
+	return metrics.NamedValues(lr.FeatureNames, lr.Coefficients())
+}
+
 // LoadData loads input and output data from a CSV file.
 func LoadData(filename string) ([][]float64, []float64, error) {
 	file, err := os.Open(filename)
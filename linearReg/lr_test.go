@@ -0,0 +1,30 @@
+package linearReg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoefficientsNamed(t *testing.T) {
+	var lr LinearRegression
+	if coefficients := lr.Coefficients(); coefficients != nil {
+		t.Fatalf("Coefficients before Fit = %v, want nil", coefficients)
+	}
+
+	// y = 1 + 2*size - 0.5*age
+	X := [][]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {2, 1}, {1, 2}}
+	y := make([]float64, len(X))
+	for i, x := range X {
+		y[i] = 1 + 2*x[0] - 0.5*x[1]
+	}
+	lr.FeatureNames = []string{"size"}
+	lr.Fit(X, y, 0.1, 5000)
+
+	named := lr.CoefficientsNamed()
+	if len(named) != 2 {
+		t.Fatalf("CoefficientsNamed = %v, want 2 entries", named)
+	}
+	if math.Abs(named["size"]-2) > 1e-3 || math.Abs(named["feature[1]"]+0.5) > 1e-3 {
+		t.Errorf("CoefficientsNamed = %v, want size 2 and feature[1] -0.5", named)
+	}
+}
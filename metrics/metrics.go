@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+)
+
+// NamedValues maps each value to its feature name, falling back to feature[i] for unnamed columns
+func NamedValues(names []string, values []float64) map[string]float64 {
+	named := make(map[string]float64, len(values))
+	for i, value := range values {
+		name := fmt.Sprintf("feature[%d]", i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		named[name] = value
+	}
+	return named
+}
+
+// CalibrationCurve bins predicted probabilities into equal-width bins over [0, 1] and returns,
+// for every non-empty bin, the mean predicted probability and the observed fraction of positive labels.
+// A well-calibrated model has meanPredicted close to observedFraction in every bin.
+// It returns an error if bins is less than 1, if probs and labels differ in length,
+// or if a probability is NaN or outside [0, 1].
+func CalibrationCurve(probs []float64, labels []float64, bins int) (meanPredicted, observedFraction []float64, err error) {
+	if bins < 1 {
+		return nil, nil, fmt.Errorf("bins must be at least 1, got %d", bins)
+	}
+	if len(probs) != len(labels) {
+		return nil, nil, fmt.Errorf("got %d probabilities but %d labels", len(probs), len(labels))
+	}
+	for i, prob := range probs {
+		if !(prob >= 0 && prob <= 1) {
+			return nil, nil, fmt.Errorf("probability %d is %v, want a value in [0, 1]", i, prob)
+		}
+	}
+
+	sumPredicted := make([]float64, bins)
+	sumPositive := make([]float64, bins)
+	counts := make([]int, bins)
+
+	for i, prob := range probs {
+		bin := int(prob * float64(bins))
+		if bin == bins {
+			bin = bins - 1
+		}
+		sumPredicted[bin] += prob
+		if labels[i] > 0 {
+			sumPositive[bin]++
+		}
+		counts[bin]++
+	}
+
+	for bin := 0; bin < bins; bin++ {
+		if counts[bin] == 0 {
+			continue
+		}
+		meanPredicted = append(meanPredicted, sumPredicted[bin]/float64(counts[bin]))
+		observedFraction = append(observedFraction, sumPositive[bin]/float64(counts[bin]))
+	}
+	return meanPredicted, observedFraction, nil
+}
+
+func main() {
+	// Predicted probabilities and true labels
+	probs := []float64{0.1, 0.15, 0.3, 0.35, 0.6, 0.65, 0.8, 0.9, 0.95}
+	labels := []float64{0, 0, 0, 1, 1, 0, 1, 1, 1}
+
+	// Compute the reliability diagram
+	meanPredicted, observedFraction, err := CalibrationCurve(probs, labels, 5)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// Print the calibration curve
+	fmt.Println("Mean Predicted:", meanPredicted)
+	fmt.Println("Observed Fraction:", observedFraction)
+}
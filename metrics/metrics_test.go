@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalibrationCurve(t *testing.T) {
+	probs := []float64{0.1, 0.2, 0.7, 0.9, 1}
+	labels := []float64{0, 1, 1, 1, 1}
+	meanPredicted, observedFraction, err := CalibrationCurve(probs, labels, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meanPredicted) != 2 || math.Abs(meanPredicted[0]-0.15) > 1e-12 || math.Abs(meanPredicted[1]-0.8667) > 1e-4 {
+		t.Errorf("meanPredicted = %v, want [0.15 0.8667]", meanPredicted)
+	}
+	if observedFraction[0] != 0.5 || observedFraction[1] != 1 {
+		t.Errorf("observedFraction = %v, want [0.5 1]", observedFraction)
+	}
+}
+
+func TestCalibrationCurveRejectsBadInput(t *testing.T) {
+	cases := map[string]struct {
+		probs, labels []float64
+		bins          int
+	}{
+		"no bins":        {[]float64{0.5}, []float64{1}, 0},
+		"missing labels": {[]float64{0.5, 0.6}, []float64{1}, 5},
+		"NaN":            {[]float64{math.NaN()}, []float64{1}, 5},
+		"out of range":   {[]float64{1.5}, []float64{1}, 5},
+	}
+	for name, c := range cases {
+		if _, _, err := CalibrationCurve(c.probs, c.labels, c.bins); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
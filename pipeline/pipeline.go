@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"fmt"
+
+	dataNormalization "ml/dataNormlization"
+	"ml/dimensionalityReduction"
+	"ml/randomForest"
+)
+
+// Transformer represents a preprocessing step that learns its parameters from the data it transforms
+type Transformer interface {
+	Fit(X [][]float64)
+	Transform(X [][]float64) [][]float64
+}
+
+// Estimator represents the model at the end of a pipeline
+type Estimator interface {
+	Fit(X [][]float64, y []float64)
+	Predict(sample []float64) float64
+}
+
+// EstimatorFuncs adapts a model's training and prediction methods to an Estimator
+type EstimatorFuncs struct {
+	FitFunc     func(X [][]float64, y []float64)
+	PredictFunc func(sample []float64) float64
+}
+
+// Fit calls FitFunc
+func (e EstimatorFuncs) Fit(X [][]float64, y []float64) {
+	e.FitFunc(X, y)
+}
+
+// Predict calls PredictFunc
+func (e EstimatorFuncs) Predict(sample []float64) float64 {
+	return e.PredictFunc(sample)
+}
+
+// ColumnScaler represents a scaler that normalizes a single feature, such as the scalers in dataNormalization
+type ColumnScaler interface {
+	Fit(data []float64)
+	Transform(val float64) float64
+}
+
+// ScalerStep applies a separate ColumnScaler to every feature
+type ScalerStep struct {
+	NewScaler func() ColumnScaler
+	scalers   []ColumnScaler
+}
+
+// NewMinMaxStep creates a step that applies Min-Max normalization to every feature
+func NewMinMaxStep() *ScalerStep {
+	return &ScalerStep{NewScaler: func() ColumnScaler { return &dataNormalization.MinMaxScaler{} }}
+}
+
+// NewZScoreStep creates a step that applies Z-score normalization to every feature
+func NewZScoreStep() *ScalerStep {
+	return &ScalerStep{NewScaler: func() ColumnScaler { return &dataNormalization.ZScoreScaler{} }}
+}
+
+// Fit fits one scaler per feature. Empty X has no features, so no scalers are fitted.
+func (step *ScalerStep) Fit(X [][]float64) {
+	if len(X) == 0 {
+		step.scalers = nil
+		return
+	}
+	step.scalers = make([]ColumnScaler, len(X[0]))
+	column := make([]float64, len(X))
+	for j := range step.scalers {
+		for i, sample := range X {
+			column[i] = sample[j]
+		}
+		step.scalers[j] = step.NewScaler()
+		step.scalers[j].Fit(column)
+	}
+}
+
+// Transform scales every feature with its fitted scaler.
+// Features without a fitted scaler, which is every feature before Fit, are copied unchanged.
+func (step *ScalerStep) Transform(X [][]float64) [][]float64 {
+	transformed := make([][]float64, len(X))
+	for i, sample := range X {
+		transformed[i] = make([]float64, len(sample))
+		for j, val := range sample {
+			if j < len(step.scalers) {
+				val = step.scalers[j].Transform(val)
+			}
+			transformed[i][j] = val
+		}
+	}
+	return transformed
+}
+
+// Pipeline chains preprocessing steps in front of an estimator so that every step is fitted on training data only
+type Pipeline struct {
+	Steps     []Transformer
+	Estimator Estimator
+}
+
+// NewPipeline creates a new pipeline
+func NewPipeline(estimator Estimator, steps ...Transformer) *Pipeline {
+	return &Pipeline{
+		Steps:     steps,
+		Estimator: estimator,
+	}
+}
+
+// Fit fits each step on the output of the previous one, then fits the estimator on the output of the last step.
+// It returns an error for empty X or a y of a different length.
+func (p *Pipeline) Fit(X [][]float64, y []float64) error {
+	if len(X) == 0 {
+		return fmt.Errorf("cannot fit a pipeline on empty data")
+	}
+	if len(X) != len(y) {
+		return fmt.Errorf("got %d samples and %d targets", len(X), len(y))
+	}
+	for _, step := range p.Steps {
+		step.Fit(X)
+		X = step.Transform(X)
+	}
+	p.Estimator.Fit(X, y)
+	return nil
+}
+
+// Transform applies the fitted steps to X
+func (p *Pipeline) Transform(X [][]float64) [][]float64 {
+	for _, step := range p.Steps {
+		X = step.Transform(X)
+	}
+	return X
+}
+
+// Predict applies the fitted steps to X and predicts every transformed sample
+func (p *Pipeline) Predict(X [][]float64) []float64 {
+	transformed := p.Transform(X)
+	predictions := make([]float64, len(transformed))
+	for i, sample := range transformed {
+		predictions[i] = p.Estimator.Predict(sample)
+	}
+	return predictions
+}
+
+func main() {
+	// Training data on very different scales
+	XTrain := [][]float64{
+		{1, 200, 0.1},
+		{2, 180, 0.2},
+		{3, 210, 0.1},
+		{8, 900, 0.9},
+		{9, 950, 0.8},
+		{10, 880, 0.9},
+	}
+	yTrain := []float64{0, 0, 0, 1, 1, 1}
+
+	// Random forest at the end of the pipeline
+	rf := randomForest.NewRandomForest(10, 3, 1, "classification")
+	estimator := EstimatorFuncs{FitFunc: rf.TrainRandomForest, PredictFunc: rf.PredictRandomForest}
+
+	// Scale, reduce to two components, then classify
+	pipeline := NewPipeline(estimator, NewZScoreStep(), &dimensionalityReduction.PCA{Components: 2})
+	if err := pipeline.Fit(XTrain, yTrain); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// The scaler and PCA fitted on the training data are reused for the test data
+	XTest := [][]float64{{2, 190, 0.15}, {9, 920, 0.85}}
+	fmt.Println("Predictions:", pipeline.Predict(XTest))
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+
+	dataNormalization "ml/dataNormlization"
+)
+
+// firstFeature is an estimator that records its training data and predicts the first feature of a sample
+type firstFeature struct {
+	X [][]float64
+}
+
+func (e *firstFeature) Fit(X [][]float64, y []float64) {
+	e.X = X
+}
+
+func (e *firstFeature) Predict(sample []float64) float64 {
+	return sample[0]
+}
+
+func TestPipelineFitsStepsOnTrainingDataOnly(t *testing.T) {
+	XTrain := [][]float64{{1, 10}, {2, 20}, {3, 30}}
+	estimator := &firstFeature{}
+	scaler := NewZScoreStep()
+	pipeline := NewPipeline(estimator, scaler)
+	if err := pipeline.Fit(XTrain, []float64{0, 1, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scaler of the first feature learned the training mean 2 and standard deviation sqrt(2/3)
+	std := math.Sqrt(2.0 / 3)
+	fitted := scaler.scalers[0].(*dataNormalization.ZScoreScaler)
+	if fitted.Mean != 2 || math.Abs(fitted.StdDev-std) > 1e-12 {
+		t.Errorf("scaler fitted mean %v and std %v, want 2 and %v", fitted.Mean, fitted.StdDev, std)
+	}
+	if math.Abs(estimator.X[0][0]+1/std) > 1e-12 {
+		t.Errorf("estimator trained on %v, want the scaled training data", estimator.X[0][0])
+	}
+
+	// Test data far from the training data is scaled with the training parameters, not its own
+	predictions := pipeline.Predict([][]float64{{100, 0}, {102, 0}})
+	for i, want := range []float64{98 / std, 100 / std} {
+		if math.Abs(predictions[i]-want) > 1e-9 {
+			t.Errorf("prediction %d = %v, want %v", i, predictions[i], want)
+		}
+	}
+	if fitted.Mean != 2 {
+		t.Errorf("predicting refitted the scaler to mean %v", fitted.Mean)
+	}
+}
+
+func TestPipelineGuardsEmptyAndUnfittedData(t *testing.T) {
+	pipeline := NewPipeline(&firstFeature{}, NewMinMaxStep())
+	if err := pipeline.Fit(nil, nil); err == nil {
+		t.Error("expected an error for empty data")
+	}
+	if err := pipeline.Fit([][]float64{{1}, {2}}, []float64{0}); err == nil {
+		t.Error("expected an error for mismatched targets")
+	}
+
+	step := NewMinMaxStep()
+	step.Fit(nil)
+	if got := step.Transform([][]float64{{5, 6}}); got[0][0] != 5 || got[0][1] != 6 {
+		t.Errorf("Transform without fitted scalers = %v, want the data unchanged", got)
+	}
+}
@@ -2,13 +2,18 @@ package randomForest
 
 import(
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"ml/metrics"
 )
 
 // RandomForest represents a Random Forest model
@@ -18,6 +23,17 @@ type RandomForest struct {
 	MaxDepth    int
 	MaxFeatures int
 	Task        string
+	MaxBins     int // Number of histogram bins per feature; 0 uses exact split finding
+	FeatureNames []string // Optional names of the input columns
+	Bootstrap   string   // "balanced" draws the same number of rows of every class for each tree; "" samples uniformly
+	NumFeatures int      // Number of input columns seen during training
+	Criterion   string   // Classification split criterion, "gini" or "entropy"; "" uses "gini"
+	MinSamplesSplit int  // Minimum number of samples a node needs to be split
+	MinSamplesLeaf  int  // Minimum number of samples on each side of a split
+	// OnTreeTrained, if set, is called after each tree with the out-of-bag error of the trees trained so far
+	// (misclassification rate or mean squared error); returning true stops training early
+	OnTreeTrained func(treeIndex int, oobError float64) bool `json:"-"`
+	rng         *rand.Rand
 }
 
 // DecisionTree represents a single decision tree in the Random Forest
@@ -26,6 +42,13 @@ type DecisionTree struct {
 	MaxDepth   int
 	MaxFeatures int
 	Task       string
+	MaxBins    int
+	binEdges   [][]float64 // Histogram bin boundaries of each feature
+	InBag      []int       `json:"-"` // Number of times each training row was drawn into the tree's bootstrap sample; not saved
+	Criterion  string      // Classification split criterion, "gini" or "entropy"; "" uses "gini"
+	MinSamplesSplit int    // Minimum number of samples a node needs to be split
+	MinSamplesLeaf  int    // Minimum number of samples on each side of a split
+	rng        *rand.Rand
 }
 
 // Node represents a node in the decision tree
@@ -33,10 +56,64 @@ type Node struct {
 	FeatureIndex int
 	Threshold    float64
 	Prediction   float64
+	Samples      int     // Number of training samples that reached the node
+	Impurity     float64 // Gini impurity (classification) or mean squared error (regression) of those samples
+	Weight       float64 // Total training weight of those samples; equal to Samples without weights
+	ClassCounts  map[float64]float64 // Number of training samples of each class in a classification leaf
 	Left         *Node
 	Right        *Node
 }
 
+// nodeJSON is the JSON form of a Node. JSON object keys must be strings, so the class counts are stored as [class, count] pairs.
+type nodeJSON struct {
+	*nodeFields
+	ClassCounts [][2]float64 `json:",omitempty"`
+}
+
+// nodeFields has the fields of Node without its methods, so that encoding it does not recurse into MarshalJSON
+type nodeFields Node
+
+// MarshalJSON encodes the node and its children
+func (node *Node) MarshalJSON() ([]byte, error) {
+	encoded := nodeJSON{nodeFields: (*nodeFields)(node)}
+	for class, count := range node.ClassCounts {
+		encoded.ClassCounts = append(encoded.ClassCounts, [2]float64{class, count})
+	}
+	sort.Slice(encoded.ClassCounts, func(i, j int) bool { return encoded.ClassCounts[i][0] < encoded.ClassCounts[j][0] })
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a node written by MarshalJSON
+func (node *Node) UnmarshalJSON(data []byte) error {
+	decoded := nodeJSON{nodeFields: (*nodeFields)(node)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	node.ClassCounts = nil
+	if decoded.ClassCounts != nil {
+		node.ClassCounts = make(map[float64]float64)
+		for _, pair := range decoded.ClassCounts {
+			node.ClassCounts[pair[0]] = pair[1]
+		}
+	}
+	return nil
+}
+
+// Save writes the trained forest, including its hyperparameters and every tree, to w as JSON.
+// The bootstrap counts in InBag grow with the training set and are left out.
+func (rf *RandomForest) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(rf)
+}
+
+// Load reads a forest written by Save from r
+func Load(r io.Reader) (*RandomForest, error) {
+	rf := &RandomForest{}
+	if err := json.NewDecoder(r).Decode(rf); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
 // NewRandomForest creates a new Random Forest model
 func NewRandomForest(numTrees, maxDepth, maxFeatures int, task string) *RandomForest {
 	return &RandomForest{
@@ -58,25 +135,31 @@ func NewDecisionTree(maxDepth, maxFeatures int, task string) *DecisionTree {
 	}
 }
 func (dt *DecisionTree) traverseTree(sample []float64, node *Node) float64 {
+	return dt.leaf(sample, node).Prediction
+}
+
+// leaf returns the leaf below node that the sample reaches
+func (dt *DecisionTree) leaf(sample []float64, node *Node) *Node {
 	if node.Left == nil && node.Right == nil {
-		return node.Prediction
+		return node
 	}
 	if sample[node.FeatureIndex] < node.Threshold {
-		return dt.traverseTree(sample, node.Left)
+		return dt.leaf(sample, node.Left)
 	}
-	return dt.traverseTree(sample, node.Right)
+	return dt.leaf(sample, node.Right)
 }
-func (dt *DecisionTree) majorityVote(predictions []float64) float64 {
-	counts := make(map[float64]int)
+func (dt *DecisionTree) majorityVote(predictions []float64, weights []float64) float64 {
+	counts := make(map[float64]float64)
 
-	for _, prediction := range predictions {
-		counts[prediction]++
+	for i, prediction := range predictions {
+		counts[prediction] += weightOf(weights, i)
 	}
 
 	var majorityPrediction float64
-	maxCount := 0
+	maxCount := 0.0
 	for prediction, count := range counts {
-		if count > maxCount {
+		// Break ties by the smaller label so that the result does not depend on map iteration order
+		if count > maxCount || (count == maxCount && prediction < majorityPrediction) {
 			maxCount = count
 			majorityPrediction = prediction
 		}
@@ -86,40 +169,196 @@ func (dt *DecisionTree) majorityVote(predictions []float64) float64 {
 }
 
 // getLeafPrediction returns the prediction value for a leaf node
-func (dt *DecisionTree) getLeafPrediction(y []float64) float64 {
+func (dt *DecisionTree) getLeafPrediction(y []float64, weights []float64) float64 {
 	// For classification tasks, return the most frequent class label
 	if dt.Task == "classification" {
-		return dt.majorityVote(y)
+		return dt.majorityVote(y, weights)
 	}
 	// For regression tasks, return the mean of the target values
-	return dt.mean(y)
+	return dt.mean(y, weights)
 }
+// SetSeed makes training reproducible: forests with the same seed trained on the same data build identical trees.
+// All randomness comes from the forest's own source, so the global math/rand source is never used.
+// Without a seed, the source is seeded from the current time.
+func (rf *RandomForest) SetSeed(seed int64) {
+	rf.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetSeed makes training of a standalone tree reproducible
+func (dt *DecisionTree) SetSeed(seed int64) {
+	dt.rng = rand.New(rand.NewSource(seed))
+}
+
 // TrainRandomForest trains the Random Forest model
 func (rf *RandomForest) TrainRandomForest(X [][]float64, y []float64) {
+	// Only invalid weights return an error
+	rf.TrainRandomForestWeighted(X, y, nil)
+}
+
+// TrainRandomForestWeighted trains the Random Forest model with a weight per training row.
+// Bootstrap samples are drawn proportionally to the weights, and every drawn row keeps its weight in the impurity
+// of each split and in the weighted vote or mean of the leaves. Nil weights train exactly like TrainRandomForest.
+// It returns an error if there is not one finite, non-negative weight per row or the weights do not have a positive
+// total, within every class for a balanced bootstrap.
+func (rf *RandomForest) TrainRandomForestWeighted(X [][]float64, y []float64, sampleWeights []float64) error {
+	balanced := rf.Bootstrap == "balanced" && rf.Task == "classification"
+	if sampleWeights != nil {
+		if err := validateWeights(y, sampleWeights, balanced); err != nil {
+			return err
+		}
+	}
 	numSamples := len(X)
+	if rf.rng == nil {
+		rf.SetSeed(time.Now().UnixNano())
+	}
+	rf.NumFeatures = len(X[0])
+
+	// Bin the features once so that every tree shares the same histogram boundaries
+	var binEdges [][]float64
+	if rf.MaxBins > 0 {
+		binEdges = computeBinEdges(X, rf.MaxBins)
+	}
+
+	var oob *oobTracker
+	if rf.OnTreeTrained != nil {
+		oob = newOOBTracker(len(X))
+	}
 
 	for i := 0; i < rf.NumTrees; i++ {
 		// Bootstrap sampling for training data
-		XSample, ySample := rf.bootstrapSample(X, y, numSamples)
+		var XSample [][]float64
+		var ySample, wSample []float64
+		var inBag []int
+		if balanced {
+			XSample, ySample, wSample, inBag = rf.balancedBootstrapSample(X, y, sampleWeights)
+		} else {
+			XSample, ySample, wSample, inBag = rf.bootstrapSample(X, y, sampleWeights, numSamples)
+		}
 
 		// Create a new decision tree
 		tree := NewDecisionTree(rf.MaxDepth, rf.MaxFeatures, rf.Task)
+		tree.Criterion = rf.Criterion
+		tree.rng = rf.rng
+		tree.MinSamplesSplit = rf.MinSamplesSplit
+		tree.MinSamplesLeaf = rf.MinSamplesLeaf
+		tree.MaxBins = rf.MaxBins
+		tree.binEdges = binEdges
+		tree.InBag = inBag
 
 		// Train the decision tree
-		tree.TrainDecisionTree(XSample, ySample)
+		tree.TrainDecisionTreeWeighted(XSample, ySample, wSample)
 
 		// Add the trained tree to the Random Forest
 		rf.Trees[i] = tree
+
+		if oob != nil {
+			oob.add(tree, X)
+			if rf.OnTreeTrained(i, oob.error(rf, y)) {
+				rf.Trees = rf.Trees[:i+1]
+				rf.NumTrees = i + 1
+				break
+			}
+		}
 	}
+	return nil
 }
 
-// PredictRandomForest predicts the output for a given input sample using the Random Forest model
-func (rf *RandomForest) PredictRandomForest(sample []float64) float64 {
-	predictions := make([]float64, rf.NumTrees)
+// validateWeights checks that there is one finite, non-negative weight per row and that the weights have a positive
+// total, within every class if perClass is set
+func validateWeights(y []float64, weights []float64, perClass bool) error {
+	if len(weights) != len(y) {
+		return fmt.Errorf("got %d weights for %d rows", len(weights), len(y))
+	}
+	totals := make(map[float64]float64)
+	for i, weight := range weights {
+		if !(weight >= 0) || math.IsInf(weight, 1) {
+			return fmt.Errorf("weight of row %d is %v, want a finite non-negative value", i, weight)
+		}
+		class := 0.0
+		if perClass {
+			class = y[i]
+		}
+		totals[class] += weight
+	}
+	for class, total := range totals {
+		if total <= 0 {
+			if perClass {
+				return fmt.Errorf("weights of class %v sum to %v, want a positive total", class, total)
+			}
+			return fmt.Errorf("weights sum to %v, want a positive total", total)
+		}
+	}
+	return nil
+}
 
-	for i, tree := range rf.Trees {
-		predictions[i] = tree.PredictDecisionTree(sample)
+// oobTracker accumulates the out-of-bag predictions of each training row as trees are added
+type oobTracker struct {
+	votes []map[float64]int // Votes for each class (classification)
+	sums  []float64         // Sum of the predictions (regression)
+	counts []int            // Number of trees for which the row was out of bag
+}
+
+// newOOBTracker creates a tracker for numSamples training rows
+func newOOBTracker(numSamples int) *oobTracker {
+	return &oobTracker{
+		votes:  make([]map[float64]int, numSamples),
+		sums:   make([]float64, numSamples),
+		counts: make([]int, numSamples),
+	}
+}
+
+// add records the tree's prediction for every row that was not in its bootstrap sample
+func (oob *oobTracker) add(tree *DecisionTree, X [][]float64) {
+	for i, sample := range X {
+		if tree.InBag[i] > 0 {
+			continue
+		}
+		prediction := tree.PredictDecisionTree(sample)
+		if oob.votes[i] == nil {
+			oob.votes[i] = make(map[float64]int)
+		}
+		oob.votes[i][prediction]++
+		oob.sums[i] += prediction
+		oob.counts[i]++
 	}
+}
+
+// error returns the misclassification rate (classification) or mean squared error (regression)
+// over the rows that have at least one out-of-bag prediction, or NaN if there are none
+func (oob *oobTracker) error(rf *RandomForest, y []float64) float64 {
+	total := 0.0
+	rows := 0
+	for i, count := range oob.counts {
+		if count == 0 {
+			continue
+		}
+		rows++
+		if rf.Task == "classification" {
+			var prediction float64
+			maxVotes := 0
+			for class, votes := range oob.votes[i] {
+				if votes > maxVotes || (votes == maxVotes && class < prediction) {
+					maxVotes = votes
+					prediction = class
+				}
+			}
+			if prediction != y[i] {
+				total++
+			}
+		} else {
+			diff := oob.sums[i]/float64(count) - y[i]
+			total += diff * diff
+		}
+	}
+	if rows == 0 {
+		return math.NaN()
+	}
+	return total / float64(rows)
+}
+
+// PredictRandomForest predicts the output for a given input sample using the Random Forest model
+func (rf *RandomForest) PredictRandomForest(sample []float64) float64 {
+	predictions := rf.TreePredictions(sample)
 
 	if rf.Task == "classification" {
 		return rf.majorityVote(predictions)
@@ -130,18 +369,227 @@ func (rf *RandomForest) PredictRandomForest(sample []float64) float64 {
 	return math.NaN()
 }
 
-// bootstrapSample performs bootstrap sampling on the dataset
-func (rf *RandomForest) bootstrapSample(X [][]float64, y []float64, numSamples int) ([][]float64, []float64) {
+// PredictWithMargin returns the prediction together with a measure of the trees' agreement:
+// the share of trees that voted for the predicted class (classification),
+// or the standard deviation of the per-tree predictions (regression)
+func (rf *RandomForest) PredictWithMargin(sample []float64) (float64, float64) {
+	predictions := rf.TreePredictions(sample)
+
+	if rf.Task == "classification" {
+		prediction := rf.majorityVote(predictions)
+		votes := 0
+		for _, vote := range predictions {
+			if vote == prediction {
+				votes++
+			}
+		}
+		return prediction, float64(votes) / float64(len(predictions))
+	} else if rf.Task == "regression" {
+		mean := rf.mean(predictions)
+		variance := 0.0
+		for _, prediction := range predictions {
+			variance += (prediction - mean) * (prediction - mean)
+		}
+		return mean, math.Sqrt(variance / float64(len(predictions)))
+	}
+
+	return math.NaN(), math.NaN()
+}
+
+// PredictProba averages the class distributions of the leaves the sample reaches in every tree.
+// The probabilities sum to 1. It returns nil for regression forests.
+func (rf *RandomForest) PredictProba(sample []float64) map[float64]float64 {
+	if rf.Task != "classification" {
+		return nil
+	}
+
+	probabilities := make(map[float64]float64)
+	for _, tree := range rf.Trees {
+		leaf := tree.leaf(sample, tree.Root)
+		total := 0.0
+		for _, count := range leaf.ClassCounts {
+			total += count
+		}
+		for class, count := range leaf.ClassCounts {
+			probabilities[class] += count / total / float64(len(rf.Trees))
+		}
+	}
+	return probabilities
+}
+
+// PredictInterval returns the mean of the per-tree predictions for a regression sample
+// together with the lower and upper percentiles (in [0, 100]) of their distribution.
+// Narrow intervals indicate that the trees largely agree on the prediction.
+func (rf *RandomForest) PredictInterval(sample []float64, lower, upper float64) (float64, float64, float64) {
+	predictions := rf.TreePredictions(sample)
+	sort.Float64s(predictions)
+	return rf.mean(predictions), percentile(predictions, lower), percentile(predictions, upper)
+}
+
+// PredictWithVariance returns the mean of the per-tree predictions for a regression sample and the
+// bias-corrected infinitesimal jackknife estimate of its variance (Wager, Hastie and Efron, 2014),
+// computed from the covariance between each training row's bootstrap inclusion counts and the tree predictions.
+// Save does not keep the inclusion counts, so the variance of a loaded forest is NaN.
+func (rf *RandomForest) PredictWithVariance(sample []float64) (mean, variance float64) {
+	predictions := rf.TreePredictions(sample)
+	mean = rf.mean(predictions)
+	if len(rf.Trees) == 0 || rf.Trees[0].InBag == nil {
+		return mean, math.NaN()
+	}
+
+	numTrees := float64(len(rf.Trees))
+	numSamples := len(rf.Trees[0].InBag)
+	for i := 0; i < numSamples; i++ {
+		meanCount := 0.0
+		for _, tree := range rf.Trees {
+			meanCount += float64(tree.InBag[i])
+		}
+		meanCount /= numTrees
+
+		covariance := 0.0
+		for b, tree := range rf.Trees {
+			covariance += (float64(tree.InBag[i]) - meanCount) * (predictions[b] - mean)
+		}
+		covariance /= numTrees
+		variance += covariance * covariance
+	}
+
+	// Remove the Monte Carlo bias caused by using a finite number of trees
+	spread := 0.0
+	for _, prediction := range predictions {
+		spread += (prediction - mean) * (prediction - mean)
+	}
+	variance -= float64(numSamples) / (numTrees * numTrees) * spread
+
+	return mean, math.Max(variance, 0)
+}
+
+// TreePredictions returns the prediction of every tree in the forest for a given sample
+func (rf *RandomForest) TreePredictions(sample []float64) []float64 {
+	predictions := make([]float64, len(rf.Trees))
+	for i, tree := range rf.Trees {
+		predictions[i] = tree.PredictDecisionTree(sample)
+	}
+	return predictions
+}
+
+// percentile returns the p-th percentile of sorted values using linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	if rank <= 0 {
+		return sorted[0]
+	}
+	if rank >= float64(len(sorted)-1) {
+		return sorted[len(sorted)-1]
+	}
+	lowerIndex := int(math.Floor(rank))
+	fraction := rank - float64(lowerIndex)
+	return sorted[lowerIndex] + fraction*(sorted[lowerIndex+1]-sorted[lowerIndex])
+}
+
+// bootstrapSample performs bootstrap sampling on the dataset, drawing rows proportionally to their weights if any.
+// It returns the weights of the drawn rows, nil without weights, and how many times each row of the dataset was drawn.
+func (rf *RandomForest) bootstrapSample(X [][]float64, y []float64, weights []float64, numSamples int) ([][]float64, []float64, []float64, []int) {
 	XSample := make([][]float64, numSamples)
 	ySample := make([]float64, numSamples)
+	inBag := make([]int, len(X))
+
+	var cumulative, wSample []float64
+	if weights != nil {
+		cumulative = cumulativeWeights(weights)
+		wSample = make([]float64, numSamples)
+	}
 
 	for i := 0; i < numSamples; i++ {
-		index := rand.Intn(numSamples)
+		var index int
+		if weights == nil {
+			index = rf.rng.Intn(numSamples)
+		} else {
+			index = rf.weightedIndex(cumulative)
+			wSample[i] = weights[index]
+		}
 		XSample[i] = X[index]
 		ySample[i] = y[index]
+		inBag[index]++
+	}
+
+	return XSample, ySample, wSample, inBag
+}
+
+// cumulativeWeights returns the running totals of weights
+func cumulativeWeights(weights []float64) []float64 {
+	cumulative := make([]float64, len(weights))
+	total := 0.0
+	for i, weight := range weights {
+		total += weight
+		cumulative[i] = total
+	}
+	return cumulative
+}
+
+// weightedIndex draws an index with probability proportional to its weight, given the cumulative weights.
+// The total weight must be positive, which validateWeights checks before any draw.
+func (rf *RandomForest) weightedIndex(cumulative []float64) int {
+	target := rf.rng.Float64() * cumulative[len(cumulative)-1]
+	index := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > target })
+	if index == len(cumulative) {
+		index--
+	}
+	return index
+}
+
+// balancedBootstrapSample draws, with replacement, as many rows of every class as the rarest class has,
+// so that the majority classes are undersampled within each tree. Within a class, rows are drawn proportionally to their weights if any.
+// It returns the weights of the drawn rows, nil without weights, and how many times each row of the dataset was drawn.
+func (rf *RandomForest) balancedBootstrapSample(X [][]float64, y []float64, weights []float64) ([][]float64, []float64, []float64, []int) {
+	rowsByClass := make(map[float64][]int)
+	for i, label := range y {
+		rowsByClass[label] = append(rowsByClass[label], i)
+	}
+
+	classes := make([]float64, 0, len(rowsByClass))
+	perClass := len(y)
+	for class, rows := range rowsByClass {
+		classes = append(classes, class)
+		if len(rows) < perClass {
+			perClass = len(rows)
+		}
+	}
+	sort.Float64s(classes)
+
+	XSample := make([][]float64, 0, perClass*len(classes))
+	ySample := make([]float64, 0, perClass*len(classes))
+	var wSample []float64
+	inBag := make([]int, len(X))
+
+	for _, class := range classes {
+		rows := rowsByClass[class]
+		var cumulative []float64
+		if weights != nil {
+			classWeights := make([]float64, len(rows))
+			for r, row := range rows {
+				classWeights[r] = weights[row]
+			}
+			cumulative = cumulativeWeights(classWeights)
+		}
+		for i := 0; i < perClass; i++ {
+			var index int
+			if weights == nil {
+				index = rows[rf.rng.Intn(len(rows))]
+			} else {
+				index = rows[rf.weightedIndex(cumulative)]
+				wSample = append(wSample, weights[index])
+			}
+			XSample = append(XSample, X[index])
+			ySample = append(ySample, y[index])
+			inBag[index]++
+		}
 	}
 
-	return XSample, ySample
+	return XSample, ySample, wSample, inBag
 }
 
 // majorityVote returns the majority vote from the predictions
@@ -155,7 +603,8 @@ func (rf *RandomForest) majorityVote(predictions []float64) float64 {
 	var majorityPrediction float64
 	maxCount := 0
 	for prediction, count := range counts {
-		if count > maxCount {
+		// Break ties by the smaller label so that the result does not depend on map iteration order
+		if count > maxCount || (count == maxCount && prediction < majorityPrediction) {
 			maxCount = count
 			majorityPrediction = prediction
 		}
@@ -175,7 +624,18 @@ func (rf *RandomForest) mean(predictions []float64) float64 {
 
 // TrainDecisionTree trains the Decision Tree model
 func (dt *DecisionTree) TrainDecisionTree(X [][]float64, y []float64) {
-	dt.Root = dt.buildTree(X, y, dt.MaxDepth)
+	dt.TrainDecisionTreeWeighted(X, y, nil)
+}
+
+// TrainDecisionTreeWeighted trains the Decision Tree model with a weight per training row; nil weights count every row once
+func (dt *DecisionTree) TrainDecisionTreeWeighted(X [][]float64, y []float64, weights []float64) {
+	if dt.rng == nil {
+		dt.SetSeed(time.Now().UnixNano())
+	}
+	if dt.MaxBins > 0 && dt.binEdges == nil {
+		dt.binEdges = computeBinEdges(X, dt.MaxBins)
+	}
+	dt.Root = dt.buildTree(X, y, weights, dt.MaxDepth)
 }
 
 // PredictDecisionTree predicts the output for a given input sample using the Decision Tree model
@@ -184,49 +644,122 @@ func (dt *DecisionTree) PredictDecisionTree(sample []float64) float64 {
 }
 
 // buildTree recursively builds the decision tree
-func (dt *DecisionTree) buildTree(X [][]float64, y []float64, depth int) *Node {
-	if len(y) == 0 {
-		return nil
+func (dt *DecisionTree) buildTree(X [][]float64, y []float64, weights []float64, depth int) *Node {
+	leaf := &Node{Prediction: dt.getLeafPrediction(y, weights), Samples: len(y), Weight: sumWeights(y, weights), Impurity: dt.impurity(y, weights)}
+	if dt.Task == "classification" {
+		leaf.ClassCounts = make(map[float64]float64)
+		for i, label := range y {
+			leaf.ClassCounts[label] += weightOf(weights, i)
+		}
+	}
+	if len(X) <= 1 || len(X[0]) == 0 {
+		return leaf
 	}
-	if depth == 0 || dt.isSameClass(y) || dt.isSameValue(X) {
-		return &Node{Prediction: dt.getLeafPrediction(y)}
+	if depth == 0 || len(y) < dt.MinSamplesSplit || dt.isSameClass(y) || dt.isSameValue(X) {
+		return leaf
 	}
 
 	numFeatures := len(X[0])
 	selectedFeatures := dt.selectFeatures(numFeatures)
 
-	bestFeatureIndex, bestThreshold := dt.findBestSplit(X, y, selectedFeatures)
+	bestFeatureIndex, bestThreshold := dt.findBestSplit(X, y, weights, selectedFeatures)
+	if bestFeatureIndex == -1 {
+		// No candidate threshold separates the data, e.g. all values fall into one histogram bin,
+		// or every split would leave fewer than MinSamplesLeaf samples on one side
+		return leaf
+	}
 
-	leftX, leftY, rightX, rightY := dt.splitData(X, y, bestFeatureIndex, bestThreshold)
+	leftX, leftY, leftW, rightX, rightY, rightW := dt.splitData(X, y, weights, bestFeatureIndex, bestThreshold)
+	if len(leftY) == 0 || len(rightY) == 0 {
+		return leaf
+	}
 
-	leftNode := dt.buildTree(leftX, leftY, depth-1)
-	rightNode := dt.buildTree(rightX, rightY, depth-1)
+	leftNode := dt.buildTree(leftX, leftY, leftW, depth-1)
+	rightNode := dt.buildTree(rightX, rightY, rightW, depth-1)
 
 	return &Node{
 		FeatureIndex: bestFeatureIndex,
 		Threshold:    bestThreshold,
+		Samples:      leaf.Samples,
+		Weight:       leaf.Weight,
+		Impurity:     leaf.Impurity,
 		Left:         leftNode,
 		Right:        rightNode,
 	}
 }
 
-// selectFeatures randomly selects a subset of features
+// FeatureImportances returns the impurity decrease of the splits on each feature, weighted by the training
+// weight reaching each split and summed over all trees, normalized so that the importances sum to 1
+func (rf *RandomForest) FeatureImportances() []float64 {
+	importances := make([]float64, rf.NumFeatures)
+	for _, tree := range rf.Trees {
+		if tree != nil {
+			addImpurityDecrease(tree.Root, importances)
+		}
+	}
+
+	total := 0.0
+	for _, importance := range importances {
+		total += importance
+	}
+	if total > 0 {
+		for i := range importances {
+			importances[i] /= total
+		}
+	}
+	return importances
+}
+
+// FeatureImportancesNamed returns FeatureImportances keyed by rf.FeatureNames, falling back to feature[i]
+func (rf *RandomForest) FeatureImportancesNamed() map[string]float64 {
+	return metrics.NamedValues(rf.FeatureNames, rf.FeatureImportances())
+}
+
+// addImpurityDecrease adds the weighted impurity decrease of every split below node to importances
+func addImpurityDecrease(node *Node, importances []float64) {
+	if node == nil || (node.Left == nil && node.Right == nil) {
+		return
+	}
+	decrease := node.Weight*node.Impurity -
+		node.Left.Weight*node.Left.Impurity -
+		node.Right.Weight*node.Right.Impurity
+	importances[node.FeatureIndex] += decrease
+	addImpurityDecrease(node.Left, importances)
+	addImpurityDecrease(node.Right, importances)
+}
+
+// selectFeatures randomly selects MaxFeatures distinct features with a partial Fisher-Yates shuffle.
+// MaxFeatures is clamped to numFeatures, and a value <= 0 selects every feature.
 func (dt *DecisionTree) selectFeatures(numFeatures int) []int {
-	selectedFeatures := make([]int, dt.MaxFeatures)
-	for i := range selectedFeatures {
-		selectedFeatures[i] = rand.Intn(numFeatures)
+	maxFeatures := dt.MaxFeatures
+	if maxFeatures <= 0 || maxFeatures > numFeatures {
+		maxFeatures = numFeatures
+	}
+
+	features := make([]int, numFeatures)
+	for i := range features {
+		features[i] = i
+	}
+	for i := 0; i < maxFeatures; i++ {
+		j := i + dt.rng.Intn(numFeatures-i)
+		features[i], features[j] = features[j], features[i]
 	}
-	return selectedFeatures
+	return features[:maxFeatures]
 }
 
 // findBestSplit finds the best feature and threshold to split the data
-func (dt *DecisionTree) findBestSplit(X [][]float64, y []float64, selectedFeatures []int) (int, float64) {
+func (dt *DecisionTree) findBestSplit(X [][]float64, y []float64, weights []float64, selectedFeatures []int) (int, float64) {
 	bestFeatureIndex := -1
 	bestThreshold := math.Inf(1)
 	bestScore := math.Inf(-1)
 
 	for _, featureIndex := range selectedFeatures {
-		threshold, score := dt.findBestSplitForFeature(X, y, featureIndex)
+		var threshold, score float64
+		if dt.MaxBins > 0 {
+			threshold, score = dt.findBestSplitForFeatureHistogram(X, y, weights, featureIndex)
+		} else {
+			threshold, score = dt.findBestSplitForFeature(X, y, weights, featureIndex)
+		}
 		if score > bestScore {
 			bestFeatureIndex = featureIndex
 			bestThreshold = threshold
@@ -238,7 +771,7 @@ func (dt *DecisionTree) findBestSplit(X [][]float64, y []float64, selectedFeatur
 }
 
 // findBestSplitForFeature finds the best threshold to split the data for a given feature
-func (dt *DecisionTree) findBestSplitForFeature(X [][]float64, y []float64, featureIndex int) (float64, float64) {
+func (dt *DecisionTree) findBestSplitForFeature(X [][]float64, y []float64, weights []float64, featureIndex int) (float64, float64) {
 	var bestThreshold float64
 	bestScore := math.Inf(-1)
 
@@ -259,16 +792,26 @@ func (dt *DecisionTree) findBestSplitForFeature(X [][]float64, y []float64, feat
 	for _, threshold := range splitPoints {
 		leftY := make([]float64, 0)
 		rightY := make([]float64, 0)
+		var leftW, rightW []float64
 
 		for i, value := range X {
 			if value[featureIndex] < threshold {
 				leftY = append(leftY, y[i])
+				if weights != nil {
+					leftW = append(leftW, weights[i])
+				}
 			} else {
 				rightY = append(rightY, y[i])
+				if weights != nil {
+					rightW = append(rightW, weights[i])
+				}
 			}
 		}
+		if len(leftY) < dt.MinSamplesLeaf || len(rightY) < dt.MinSamplesLeaf {
+			continue
+		}
 
-		score := dt.calculateScore(leftY, rightY)
+		score := dt.calculateScore(leftY, leftW, rightY, rightW)
 		if score > bestScore {
 			bestThreshold = threshold
 			bestScore = score
@@ -278,20 +821,145 @@ func (dt *DecisionTree) findBestSplitForFeature(X [][]float64, y []float64, feat
 	return bestThreshold, bestScore
 }
 
+// findBestSplitForFeatureHistogram finds the best bin-boundary threshold to split the data for a given feature.
+// The target statistics of each bin are accumulated in a single pass, so each threshold is scored without rescanning the data.
+func (dt *DecisionTree) findBestSplitForFeatureHistogram(X [][]float64, y []float64, weights []float64, featureIndex int) (float64, float64) {
+	var bestThreshold float64
+	bestScore := math.Inf(-1)
+
+	// Accumulate target statistics per bin
+	edges := dt.binEdges[featureIndex]
+	bins := make([]*splitStats, len(edges)+1)
+	for b := range bins {
+		bins[b] = newSplitStats()
+	}
+	total := newSplitStats()
+	for i := range X {
+		value := X[i][featureIndex]
+		b := sort.Search(len(edges), func(k int) bool { return edges[k] > value })
+		bins[b].add(y[i], weightOf(weights, i))
+		total.add(y[i], weightOf(weights, i))
+	}
+
+	// Evaluate bin boundaries, moving one bin at a time from the right side to the left side
+	left := newSplitStats()
+	for b, threshold := range edges {
+		left.merge(bins[b])
+		right := total.subtract(left)
+		if left.samples == 0 || right.samples == 0 || left.samples < dt.MinSamplesLeaf || right.samples < dt.MinSamplesLeaf {
+			continue
+		}
+
+		score := dt.calculateStatsScore(left, right)
+		if score > bestScore {
+			bestThreshold = threshold
+			bestScore = score
+		}
+	}
+
+	return bestThreshold, bestScore
+}
+
+// splitStats holds the target statistics of the samples on one side of a split
+type splitStats struct {
+	classCounts map[float64]float64
+	samples     int     // Number of samples
+	count       float64 // Total weight of the samples
+	sum         float64
+	sumSquares  float64
+}
+
+// newSplitStats creates empty split statistics
+func newSplitStats() *splitStats {
+	return &splitStats{classCounts: make(map[float64]float64)}
+}
+
+// add adds a target value with the given weight to the statistics
+func (s *splitStats) add(value, weight float64) {
+	s.classCounts[value] += weight
+	s.samples++
+	s.count += weight
+	s.sum += weight * value
+	s.sumSquares += weight * value * value
+}
+
+// merge adds the statistics of other to s
+func (s *splitStats) merge(other *splitStats) {
+	for label, count := range other.classCounts {
+		s.classCounts[label] += count
+	}
+	s.samples += other.samples
+	s.count += other.count
+	s.sum += other.sum
+	s.sumSquares += other.sumSquares
+}
+
+// subtract returns the statistics of s with those of other removed
+func (s *splitStats) subtract(other *splitStats) *splitStats {
+	result := newSplitStats()
+	for label, count := range s.classCounts {
+		result.classCounts[label] = count - other.classCounts[label]
+	}
+	result.samples = s.samples - other.samples
+	result.count = s.count - other.count
+	result.sum = s.sum - other.sum
+	result.sumSquares = s.sumSquares - other.sumSquares
+	return result
+}
+
+// calculateStatsScore calculates the score for a split from the statistics of both sides
+func (dt *DecisionTree) calculateStatsScore(left, right *splitStats) float64 {
+	totalSize := left.count + right.count
+	weightedImpurity := (left.count/totalSize)*dt.statsImpurity(left) + (right.count/totalSize)*dt.statsImpurity(right)
+	return -weightedImpurity
+}
+
+// statsImpurity returns the class impurity (classification) or mean squared error (regression) of split statistics
+func (dt *DecisionTree) statsImpurity(s *splitStats) float64 {
+	if dt.Task == "classification" {
+		return dt.classImpurity(s.classCounts, s.count)
+	} else if dt.Task == "regression" {
+		mean := s.sum / s.count
+		return s.sumSquares/s.count - mean*mean
+	}
+	return math.NaN()
+}
+
+// computeBinEdges computes up to maxBins-1 quantile bin boundaries for every feature
+func computeBinEdges(X [][]float64, maxBins int) [][]float64 {
+	numFeatures := len(X[0])
+	edges := make([][]float64, numFeatures)
+	for j := 0; j < numFeatures; j++ {
+		values := make([]float64, len(X))
+		for i := range X {
+			values[i] = X[i][j]
+		}
+		sort.Float64s(values)
+
+		for b := 1; b < maxBins; b++ {
+			edge := values[b*len(values)/maxBins]
+			if edge > values[0] && (len(edges[j]) == 0 || edge > edges[j][len(edges[j])-1]) {
+				edges[j] = append(edges[j], edge)
+			}
+		}
+	}
+	return edges
+}
+
 // calculateScore calculates the score for a given split
-func (dt *DecisionTree) calculateScore(leftY, rightY []float64) float64 {
-	leftSize := float64(len(leftY))
-	rightSize := float64(len(rightY))
+func (dt *DecisionTree) calculateScore(leftY, leftW, rightY, rightW []float64) float64 {
+	leftSize := sumWeights(leftY, leftW)
+	rightSize := sumWeights(rightY, rightW)
 	totalSize := leftSize + rightSize
 
 	if dt.Task == "classification" {
-		leftGini := dt.giniImpurity(leftY)
-		rightGini := dt.giniImpurity(rightY)
-		weightedGini := (leftSize/totalSize)*leftGini + (rightSize/totalSize)*rightGini
-		return -weightedGini // Minimize Gini impurity
+		leftImpurity := dt.labelImpurity(leftY, leftW)
+		rightImpurity := dt.labelImpurity(rightY, rightW)
+		weightedImpurity := (leftSize/totalSize)*leftImpurity + (rightSize/totalSize)*rightImpurity
+		return -weightedImpurity // Minimize Gini impurity or entropy, i.e. maximize information gain
 	} else if dt.Task == "regression" {
-		leftMSE := dt.meanSquaredError(leftY)
-		rightMSE := dt.meanSquaredError(rightY)
+		leftMSE := dt.meanSquaredError(leftY, leftW)
+		rightMSE := dt.meanSquaredError(rightY, rightW)
 		weightedMSE := (leftSize/totalSize)*leftMSE + (rightSize/totalSize)*rightMSE
 		return -weightedMSE // Minimize mean squared error
 	}
@@ -299,38 +967,80 @@ func (dt *DecisionTree) calculateScore(leftY, rightY []float64) float64 {
 	return math.NaN()
 }
 
-// giniImpurity calculates the Gini impurity for a given set of labels
-func (dt *DecisionTree) giniImpurity(y []float64) float64 {
-	classCounts := make(map[float64]int)
-	for _, label := range y {
-		classCounts[label]++
+// impurity returns the class impurity (classification) or mean squared error (regression) of y
+func (dt *DecisionTree) impurity(y []float64, weights []float64) float64 {
+	if len(y) == 0 {
+		return 0
 	}
+	if dt.Task == "classification" {
+		return dt.labelImpurity(y, weights)
+	}
+	return dt.meanSquaredError(y, weights)
+}
 
+// labelImpurity calculates the class impurity for a given set of weighted labels
+func (dt *DecisionTree) labelImpurity(y []float64, weights []float64) float64 {
+	classCounts := make(map[float64]float64)
+	for i, label := range y {
+		classCounts[label] += weightOf(weights, i)
+	}
+	return dt.classImpurity(classCounts, sumWeights(y, weights))
+}
+
+// classImpurity calculates the Gini impurity, or with the "entropy" criterion the entropy -sum(p*log2 p), of class counts
+func (dt *DecisionTree) classImpurity(classCounts map[float64]float64, total float64) float64 {
 	var impurity float64
 	for _, count := range classCounts {
-		prob := float64(count) / float64(len(y))
-		impurity += prob * (1 - prob)
+		if count <= 0 {
+			continue
+		}
+		prob := count / total
+		if dt.Criterion == "entropy" {
+			impurity -= prob * math.Log2(prob)
+		} else {
+			impurity += prob * (1 - prob)
+		}
 	}
 	return impurity
 }
 
-// meanSquaredError calculates the mean squared error for a given set of values
-func (dt *DecisionTree) meanSquaredError(y []float64) float64 {
-	mean := dt.mean(y)
+// meanSquaredError calculates the weighted mean squared error for a given set of values
+func (dt *DecisionTree) meanSquaredError(y []float64, weights []float64) float64 {
+	mean := dt.mean(y, weights)
 	var mse float64
-	for _, value := range y {
-		mse += math.Pow(value-mean, 2)
+	for i, value := range y {
+		mse += weightOf(weights, i) * math.Pow(value-mean, 2)
 	}
-	return mse / float64(len(y))
+	return mse / sumWeights(y, weights)
 }
 
-// mean calculates the mean of a slice of values
-func (dt *DecisionTree) mean(y []float64) float64 {
+// mean calculates the weighted mean of a slice of values
+func (dt *DecisionTree) mean(y []float64, weights []float64) float64 {
 	sum := 0.0
-	for _, value := range y {
-		sum += value
+	for i, value := range y {
+		sum += weightOf(weights, i) * value
+	}
+	return sum / sumWeights(y, weights)
+}
+
+// sumWeights returns the total weight of the values in y, treating nil weights as uniform
+func sumWeights(y []float64, weights []float64) float64 {
+	if weights == nil {
+		return float64(len(y))
+	}
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
 	}
-	return sum / float64(len(y))
+	return total
+}
+
+// weightOf returns the weight of the i-th sample, treating nil weights as uniform
+func weightOf(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
 }
 
 // isSameClass checks if all elements in y belong to the same class
@@ -346,7 +1056,7 @@ func (dt *DecisionTree) isSameClass(y []float64) bool {
 // isSameValue checks if all elements in X belong to the same value
 func (dt *DecisionTree) isSameValue(X [][]float64) bool {
 	for i := 1; i < len(X); i++ {
-		for j := 1; j < len(X[i]); j++ {
+		for j := 0; j < len(X[i]); j++ {
 			if X[i][j] != X[0][j] {
 				return false
 			}
@@ -355,24 +1065,32 @@ func (dt *DecisionTree) isSameValue(X [][]float64) bool {
 	return true
 }
 
-// splitData splits the dataset into left and right based on the threshold
-func (dt *DecisionTree) splitData(X [][]float64, y []float64, featureIndex int, threshold float64) ([][]float64, []float64, [][]float64, []float64) {
+// splitData splits the dataset and its weights into left and right based on the threshold.
+// The weights of both sides are nil when weights is nil.
+func (dt *DecisionTree) splitData(X [][]float64, y []float64, weights []float64, featureIndex int, threshold float64) ([][]float64, []float64, []float64, [][]float64, []float64, []float64) {
 	leftX := make([][]float64, 0)
 	leftY := make([]float64, 0)
 	rightX := make([][]float64, 0)
 	rightY := make([]float64, 0)
+	var leftW, rightW []float64
 
 	for i := range X {
 		if X[i][featureIndex] < threshold {
 			leftX = append(leftX, X[i])
 			leftY = append(leftY, y[i])
+			if weights != nil {
+				leftW = append(leftW, weights[i])
+			}
 		} else {
 			rightX = append(rightX, X[i])
 			rightY = append(rightY, y[i])
+			if weights != nil {
+				rightW = append(rightW, weights[i])
+			}
 		}
 	}
 
-	return leftX, leftY, rightX, rightY
+	return leftX, leftY, leftW, rightX, rightY, rightW
 }
 
 // loadData loads data from a CSV file
@@ -0,0 +1,397 @@
+package randomForest
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// signalData returns rows whose label depends only on the second column
+func signalData() ([][]float64, []float64) {
+	var X [][]float64
+	var y []float64
+	for i := 0; i < 40; i++ {
+		label := float64(i % 2)
+		X = append(X, []float64{float64(i % 7), label*4 + float64(i%3)/10})
+		y = append(y, label)
+	}
+	return X, y
+}
+
+// circleData returns n rows of two uniform features and a random noise feature, labelled 1 inside a circle
+func circleData(rng *rand.Rand, n int) ([][]float64, []float64) {
+	X := make([][]float64, n)
+	y := make([]float64, n)
+	for i := range X {
+		X[i] = []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+		if (X[i][0]-0.5)*(X[i][0]-0.5)+(X[i][1]-0.5)*(X[i][1]-0.5) < 0.1 {
+			y[i] = 1
+		}
+	}
+	return X, y
+}
+
+// accuracy returns the fraction of rows the forest predicts correctly
+func accuracy(rf *RandomForest, X [][]float64, y []float64) float64 {
+	correct := 0
+	for i, sample := range X {
+		if rf.PredictRandomForest(sample) == y[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(X))
+}
+
+// leafSizes appends the number of training samples of every leaf below node
+func leafSizes(node *Node, sizes []int) []int {
+	if node.Left == nil && node.Right == nil {
+		return append(sizes, node.Samples)
+	}
+	return leafSizes(node.Right, leafSizes(node.Left, sizes))
+}
+
+// treeHeight returns the number of edges on the longest path from node to a leaf
+func treeHeight(node *Node) int {
+	if node.Left == nil && node.Right == nil {
+		return 0
+	}
+	return 1 + max(treeHeight(node.Left), treeHeight(node.Right))
+}
+
+func TestFeatureImportancesNamed(t *testing.T) {
+	X, y := signalData()
+	rf := NewRandomForest(10, 3, 2, "classification")
+	rf.SetSeed(1)
+	rf.FeatureNames = []string{"noise", "signal"}
+	rf.TrainRandomForest(X, y)
+
+	named := rf.FeatureImportancesNamed()
+	if len(named) != 2 || named["signal"] < 0.9 {
+		t.Errorf("FeatureImportancesNamed = %v, want most importance on signal", named)
+	}
+}
+
+func TestWeightedTrainingUsesWeights(t *testing.T) {
+	X, y := signalData()
+	weights := make([]float64, len(X))
+	for i := range weights {
+		weights[i] = 1
+		if y[i] == 1 {
+			weights[i] = 3
+		}
+	}
+	rf := NewRandomForest(20, 3, 2, "classification")
+	rf.SetSeed(1)
+	if err := rf.TrainRandomForestWeighted(X, y, weights); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows of weight 3 are drawn about three times as often as rows of weight 1
+	drawn := map[float64]int{}
+	for _, tree := range rf.Trees {
+		rootWeight := 0.0
+		for i, count := range tree.InBag {
+			drawn[y[i]] += count
+			rootWeight += float64(count) * weights[i]
+		}
+		// and each drawn row keeps its weight in the tree
+		if math.Abs(tree.Root.Weight-rootWeight) > 1e-9 || tree.Root.Samples != len(X) {
+			t.Fatalf("root weight %v over %d samples, want %v over %d", tree.Root.Weight, tree.Root.Samples, rootWeight, len(X))
+		}
+	}
+	ratio := float64(drawn[1]) / float64(drawn[0])
+	if ratio < 2.5 || ratio > 3.5 {
+		t.Errorf("weight-3 rows drawn %v times as often as weight-1 rows, want about 3", ratio)
+	}
+}
+
+func TestWeightedLeafVote(t *testing.T) {
+	// Identical samples cannot be split, so the single leaf votes by weight: one row of class 1 outweighs two of class 0
+	X := [][]float64{{1}, {1}, {1}}
+	y := []float64{0, 0, 1}
+	tree := NewDecisionTree(3, 1, "classification")
+	tree.SetSeed(1)
+	tree.TrainDecisionTreeWeighted(X, y, []float64{1, 1, 5})
+	if prediction := tree.PredictDecisionTree([]float64{1}); prediction != 1 {
+		t.Errorf("weighted leaf predicts %v, want 1", prediction)
+	}
+
+	regression := NewDecisionTree(3, 1, "regression")
+	regression.SetSeed(1)
+	regression.TrainDecisionTreeWeighted(X, []float64{0, 0, 6}, []float64{1, 1, 4})
+	if prediction := regression.PredictDecisionTree([]float64{1}); prediction != 4 {
+		t.Errorf("weighted leaf mean %v, want 24/6 = 4", prediction)
+	}
+}
+
+func TestTrainRandomForestWeightedRejectsInvalidWeights(t *testing.T) {
+	X, y := signalData()
+	for _, weights := range [][]float64{make([]float64, len(X)), make([]float64, len(X)-1)} {
+		rf := NewRandomForest(2, 3, 2, "classification")
+		rf.SetSeed(1)
+		if err := rf.TrainRandomForestWeighted(X, y, weights); err == nil {
+			t.Errorf("%d weights summing to 0: expected an error", len(weights))
+		}
+	}
+
+	// A balanced bootstrap needs positive weight in every class
+	weights := make([]float64, len(X))
+	for i := range weights {
+		if y[i] == 1 {
+			weights[i] = 1
+		}
+	}
+	rf := NewRandomForest(2, 3, 2, "classification")
+	rf.SetSeed(1)
+	rf.Bootstrap = "balanced"
+	if err := rf.TrainRandomForestWeighted(X, y, weights); err == nil {
+		t.Error("expected an error for a class with zero total weight")
+	}
+	weights[0] = -1
+	rf.Bootstrap = ""
+	if err := rf.TrainRandomForestWeighted(X, y, weights); err == nil {
+		t.Error("expected an error for a negative weight")
+	}
+}
+
+func TestNodeWeightIsTrainingWeight(t *testing.T) {
+	X, y := signalData()
+	weights := make([]float64, len(X))
+	for i := range weights {
+		weights[i] = 0.5
+	}
+	tree := NewDecisionTree(3, 2, "classification")
+	tree.SetSeed(1)
+	tree.TrainDecisionTreeWeighted(X, y, weights)
+	if tree.Root.Weight != 0.5*float64(len(X)) {
+		t.Errorf("root weight %v, want %v", tree.Root.Weight, 0.5*float64(len(X)))
+	}
+}
+
+func TestHistogramSplits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	X, y := circleData(rng, 400)
+	XTest, yTest := circleData(rng, 400)
+
+	exact := NewRandomForest(10, 6, 2, "classification")
+	exact.SetSeed(1)
+	exact.TrainRandomForest(X, y)
+	histogram := NewRandomForest(10, 6, 2, "classification")
+	histogram.SetSeed(1)
+	histogram.MaxBins = 32
+	histogram.TrainRandomForest(X, y)
+
+	if exactAccuracy, histogramAccuracy := accuracy(exact, XTest, yTest), accuracy(histogram, XTest, yTest); histogramAccuracy < exactAccuracy-0.03 {
+		t.Errorf("histogram accuracy %v, exact %v", histogramAccuracy, exactAccuracy)
+	}
+}
+
+func BenchmarkTrainRandomForest(b *testing.B) {
+	X, y := circleData(rand.New(rand.NewSource(1)), 1000)
+	for _, maxBins := range []int{0, 32} {
+		name := "exact"
+		if maxBins > 0 {
+			name = "histogram"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rf := NewRandomForest(10, 8, 2, "classification")
+				rf.SetSeed(1)
+				rf.MaxBins = maxBins
+				rf.TrainRandomForest(X, y)
+			}
+		})
+	}
+}
+
+func TestTinyAndSingleClassData(t *testing.T) {
+	// A single row becomes a leaf predicting its target
+	tree := NewDecisionTree(5, 1, "regression")
+	tree.SetSeed(1)
+	tree.TrainDecisionTree([][]float64{{1, 2}}, []float64{4.5})
+	if prediction := tree.PredictDecisionTree([]float64{0, 0}); prediction != 4.5 {
+		t.Errorf("single-row tree predicts %v, want 4.5", prediction)
+	}
+
+	// Every bootstrap sample of a single-class dataset trains a leaf predicting that class
+	rf := NewRandomForest(5, 5, 1, "classification")
+	rf.SetSeed(1)
+	rf.TrainRandomForest([][]float64{{1}, {2}, {3}}, []float64{2, 2, 2})
+	if prediction := rf.PredictRandomForest([]float64{10}); prediction != 2 {
+		t.Errorf("single-class forest predicts %v, want 2", prediction)
+	}
+	rf.TrainRandomForest([][]float64{{1}}, []float64{7})
+	if prediction := rf.PredictRandomForest([]float64{10}); prediction != 7 {
+		t.Errorf("single-row forest predicts %v, want 7", prediction)
+	}
+}
+
+func TestBalancedBootstrap(t *testing.T) {
+	// One positive for every nineteen negatives
+	rng := rand.New(rand.NewSource(2))
+	var X [][]float64
+	var y []float64
+	for i := 0; i < 400; i++ {
+		label := 0.0
+		if i%20 == 0 {
+			label = 1
+		}
+		X = append(X, []float64{label + rng.NormFloat64()*0.6, rng.NormFloat64()})
+		y = append(y, label)
+	}
+
+	recall := func(rf *RandomForest) float64 {
+		found, positives := 0, 0
+		for i, sample := range X {
+			if y[i] == 1 {
+				positives++
+				if rf.PredictRandomForest(sample) == 1 {
+					found++
+				}
+			}
+		}
+		return float64(found) / float64(positives)
+	}
+
+	uniform := NewRandomForest(25, 3, 2, "classification")
+	uniform.SetSeed(1)
+	uniform.TrainRandomForest(X, y)
+	balanced := NewRandomForest(25, 3, 2, "classification")
+	balanced.SetSeed(1)
+	balanced.Bootstrap = "balanced"
+	balanced.TrainRandomForest(X, y)
+
+	// Every tree draws as many rows of each class
+	for _, tree := range balanced.Trees {
+		drawn := map[float64]int{}
+		for i, count := range tree.InBag {
+			drawn[y[i]] += count
+		}
+		if drawn[0] != drawn[1] {
+			t.Fatalf("balanced tree drew %d negatives and %d positives", drawn[0], drawn[1])
+		}
+	}
+	if recall(balanced) <= recall(uniform) {
+		t.Errorf("balanced minority recall %v not above uniform %v", recall(balanced), recall(uniform))
+	}
+}
+
+func TestNoiseFeatureHasLowImportance(t *testing.T) {
+	X, y := circleData(rand.New(rand.NewSource(3)), 300)
+	rf := NewRandomForest(15, 5, 1, "classification")
+	rf.SetSeed(1)
+	rf.TrainRandomForest(X, y)
+
+	importances := rf.FeatureImportances()
+	if len(importances) != 3 {
+		t.Fatalf("got %d importances, want 3", len(importances))
+	}
+	sum := importances[0] + importances[1] + importances[2]
+	if sum < 0.999 || sum > 1.001 {
+		t.Errorf("importances sum to %v, want 1", sum)
+	}
+	if importances[2] > 0.2 || importances[2] > importances[0]/2 || importances[2] > importances[1]/2 {
+		t.Errorf("noise feature importance %v not well below the signal features %v", importances[2], importances[:2])
+	}
+}
+
+func TestMinSamples(t *testing.T) {
+	X, y := circleData(rand.New(rand.NewSource(4)), 300)
+	unrestricted := NewDecisionTree(20, 3, "classification")
+	unrestricted.SetSeed(1)
+	unrestricted.TrainDecisionTree(X, y)
+
+	tree := NewDecisionTree(20, 3, "classification")
+	tree.SetSeed(1)
+	tree.MinSamplesSplit = 20
+	tree.MinSamplesLeaf = 8
+	tree.TrainDecisionTree(X, y)
+
+	for _, size := range leafSizes(tree.Root, nil) {
+		if size < 8 {
+			t.Errorf("leaf with %d samples, want at least MinSamplesLeaf 8", size)
+		}
+	}
+	if treeHeight(tree.Root) >= treeHeight(unrestricted.Root) {
+		t.Errorf("restricted tree height %d not below unrestricted %d", treeHeight(tree.Root), treeHeight(unrestricted.Root))
+	}
+}
+
+func TestPredictWithMargin(t *testing.T) {
+	X, y := signalData()
+	rf := NewRandomForest(15, 3, 2, "classification")
+	rf.SetSeed(1)
+	rf.TrainRandomForest(X, y)
+
+	// Every tree separates the classes, so the forest is unanimous
+	prediction, margin := rf.PredictWithMargin([]float64{3, 4})
+	if prediction != 1 || margin != 1 {
+		t.Errorf("PredictWithMargin = %v, %v; want class 1 with margin 1", prediction, margin)
+	}
+
+	regression := NewRandomForest(15, 3, 2, "regression")
+	regression.SetSeed(1)
+	regression.TrainRandomForest([][]float64{{1}, {2}, {3}}, []float64{5, 5, 5})
+	if prediction, spread := regression.PredictWithMargin([]float64{2}); prediction != 5 || spread != 0 {
+		t.Errorf("regression PredictWithMargin = %v, %v; want 5 with no spread", prediction, spread)
+	}
+}
+
+func TestSaveLoadPreservesPredictions(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	X, y := circleData(rng, 200)
+	XTest, _ := circleData(rng, 50)
+	yRegression := make([]float64, len(X))
+	for i, sample := range X {
+		yRegression[i] = sample[0] + 2*sample[1]
+	}
+
+	for _, task := range []string{"classification", "regression"} {
+		rf := NewRandomForest(10, 5, 2, task)
+		rf.SetSeed(1)
+		if task == "classification" {
+			rf.TrainRandomForest(X, y)
+		} else {
+			rf.TrainRandomForest(X, yRegression)
+		}
+
+		var buf bytes.Buffer
+		if err := rf.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(buf.Bytes(), []byte("InBag")) {
+			t.Errorf("%s: saved model contains the bootstrap counts", task)
+		}
+		loaded, err := Load(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, sample := range XTest {
+			if want, got := rf.PredictRandomForest(sample), loaded.PredictRandomForest(sample); want != got {
+				t.Fatalf("%s: sample %d predicted %v after loading, want %v", task, i, got, want)
+			}
+		}
+		if task == "classification" {
+			if want, got := rf.PredictProba(XTest[0]), loaded.PredictProba(XTest[0]); !reflect.DeepEqual(want, got) {
+				t.Errorf("probabilities %v after loading, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestSameSeedBuildsSameForest(t *testing.T) {
+	X, y := circleData(rand.New(rand.NewSource(3)), 200)
+	train := func() *RandomForest {
+		rf := NewRandomForest(10, 5, 2, "classification")
+		rf.SetSeed(42)
+		rf.TrainRandomForest(X, y)
+		return rf
+	}
+
+	first, second := train(), train()
+	if !reflect.DeepEqual(first.Trees, second.Trees) {
+		t.Error("forests trained with the same seed on the same data differ")
+	}
+}